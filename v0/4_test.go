@@ -49,3 +49,12 @@ func JqGetString(data any, query string) string { // note, no error returned to
 	}
 	return str
 }
+
+func JqMust(t *testing.T, data any, query string) any {
+	t.Helper()
+	v, err := JqGet(data, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
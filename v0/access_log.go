@@ -0,0 +1,242 @@
+package mlog
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// AccessLogPromotedAttrs are keys of [AccessLogMiddleware] attributes, which are worth to show
+// before the message by [HumanReadableHandlerOptions.PromotedAttrs]:
+//
+//	2024-01-02T15:04:05.000000Z I --  GET /users 200 1.5ms  request  ATTRS={"bytes":42,...}
+var AccessLogPromotedAttrs = []string{"method", "path", "status", DurationKey} //nolint:gochecknoglobals
+
+// AccessLogOptions are options for an [AccessLogMiddleware].
+type AccessLogOptions struct {
+	// Logger writes access records. If nil, [slog.Default] at the request time is used.
+	Logger *slog.Logger
+
+	// Message is a message of access records. If empty, [DefaultAccessLogMessage] is used.
+	Message string
+
+	// RequestIDHeader is a name of the request header with the request ID; the ID is generated
+	// if the request has no one. The ID is returned in the response header of the same name.
+	// If empty, [DefaultRequestIDHeader] is used.
+	RequestIDHeader string
+
+	// Level returns the level of the access record by the response status.
+	// If nil, [slog.LevelError] is used for 5xx, [slog.LevelWarn] for 4xx and [slog.LevelInfo] for others.
+	Level func(status int) slog.Level
+
+	// Route, if not nil, returns the route pattern of the request, like "/users/{id}". It is called
+	// after the next handler with the request, passed to it, so it may return the Pattern field,
+	// filled by [http.ServeMux].
+	Route func(r *http.Request) string
+
+	// Skip, if not nil, is called to check whether the request should not be logged, like health checks.
+	Skip func(r *http.Request) bool
+}
+
+// AccessLogMiddleware returns [http.Handler], which logs one record per request with method, path, route,
+// status, bytes of the response body, duration, remote address, user agent and request ID.
+// The request context carries the request-scoped logger with the [RequestIDKey] attribute,
+// see [LoggerFromContext]. Panics of the next handler are recovered and logged with the stack trace
+// at [slog.LevelError], the client gets 500 status if the response is not started yet, otherwise
+// the connection is aborted by panic with [http.ErrAbortHandler], so the client doesn't take the truncated
+// response as complete; the access record has 500 status in both cases. [http.ErrAbortHandler] is passed through.
+// If opts is nil, the default options are used.
+func AccessLogMiddleware(next http.Handler, opts *AccessLogOptions) http.Handler {
+	o := AccessLogOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Message == "" {
+		o.Message = DefaultAccessLogMessage
+	}
+	if o.RequestIDHeader == "" {
+		o.RequestIDHeader = DefaultRequestIDHeader
+	}
+	if o.Level == nil {
+		o.Level = statusLevel
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.Skip != nil && o.Skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		requestID := r.Header.Get(o.RequestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set(o.RequestIDHeader, requestID)
+
+		logger := o.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger = logger.With(RequestIDKey, requestID)
+		r = r.WithContext(WithLogger(r.Context(), logger))
+		aw := &accessLogWriter{ResponseWriter: w}
+
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler { //nolint:errorlint,goerr113 // the sentinel value of panic
+					panic(p)
+				}
+				logPanic(r.Context(), logger, p)
+				if aw.status == 0 {
+					http.Error(aw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				} else {
+					aw.status = http.StatusInternalServerError
+					defer panic(http.ErrAbortHandler) // after the access record is logged
+				}
+			}
+			status := aw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := o.Level(status)
+			if !logger.Enabled(r.Context(), level) {
+				return
+			}
+			attrs := make([]slog.Attr, 0, 8) //nolint:gomnd
+			attrs = append(attrs, slog.String("method", r.Method), slog.String("path", r.URL.Path))
+			if o.Route != nil {
+				if route := o.Route(r); route != "" {
+					attrs = append(attrs, slog.String("route", route))
+				}
+			}
+			attrs = append(attrs,
+				slog.Int("status", status),
+				slog.Int64("bytes", aw.bytes),
+				slog.Duration(DurationKey, time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+			if ua := r.UserAgent(); ua != "" {
+				attrs = append(attrs, slog.String("user_agent", ua))
+			}
+			rec := slog.NewRecord(time.Now(), level, o.Message, 0)
+			rec.AddAttrs(attrs...)
+			_ = logger.Handler().Handle(r.Context(), rec)
+		}()
+		next.ServeHTTP(aw, r)
+	})
+}
+
+// statusLevel is the default level of access records.
+func statusLevel(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+// logPanic logs the recovered panic value with the stack trace of the panic.
+func logPanic(ctx context.Context, logger *slog.Logger, p any) {
+	err, ok := p.(error)
+	if !ok {
+		err = fmt.Errorf("%v", p) //nolint:goerr113
+	}
+	stack := panicStack()
+	logger.LogAttrs(ctx, slog.LevelError, "panic",
+		slog.Any(ErrorKey, &stackError{err: err, callers: stack}),
+		slog.Any(StackKey, formatStack(stack)),
+	)
+}
+
+// panicStack returns the stack trace of the panicking goroutine, starting at the frame, which panicked.
+// It must be called by the deferred function, which recovers the panic.
+func panicStack() []uintptr {
+	pcs := callers(3) //nolint:gomnd // skip runtime.Callers, callers() and panicStack()
+	for i, pc := range pcs {
+		if fn := runtime.FuncForPC(pc - 1); fn != nil && fn.Name() == "runtime.gopanic" {
+			return pcs[i+1:]
+		}
+	}
+	return pcs
+}
+
+// newRequestID returns random hex ID.
+func newRequestID() string {
+	var b [requestIDSize]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// -----------------------------------------------------------------------------
+
+// accessLogWriter counts the response status and size.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessLogWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err //nolint:wrapcheck
+}
+
+// Flush implements [http.Flusher] interface, if the wrapped writer does.
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements [http.Hijacker] interface, if the wrapped writer does, like for WebSocket upgrades.
+// The response status is 101 then.
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: Hijack", http.ErrNotSupported)
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err //nolint:wrapcheck
+}
+
+// ReadFrom implements [io.ReaderFrom] interface, so [io.Copy] keeps using the one of the wrapped writer,
+// like sendfile of the connection.
+func (w *accessLogWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := io.Copy(w.ResponseWriter, r)
+	w.bytes += n
+	return n, err //nolint:wrapcheck
+}
+
+// Unwrap returns the wrapped writer for [http.ResponseController].
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
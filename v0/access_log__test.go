@@ -0,0 +1,170 @@
+package mlog_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__AccessLog__HumanReadable(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(buf, &mlog.HumanReadableHandlerOptions{
+		PromotedAttrs: mlog.AccessLogPromotedAttrs,
+		Level:         slog.LevelDebug,
+	}))
+	h := mlog.AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mlog.LoggerFromContext(r.Context()).Debug("lookup", "id", 7)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("nope")) //nolint:errcheck
+	}), &mlog.AccessLogOptions{
+		Logger: logger,
+		Route:  func(*http.Request) string { return "/users/{id}" },
+	})
+
+	req := httptest.NewRequest("GET", "/users/7?full=1", nil)
+	req.Header.Set(mlog.DefaultRequestIDHeader, "r-1")
+	req.Header.Set("User-Agent", "test/1.0")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	tt.EqualValues(http.StatusNotFound, rec.Code)
+	tt.EqualValues("r-1", rec.Header().Get(mlog.DefaultRequestIDHeader))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	tt.Len(lines, 2)
+	tt.Contains(lines[0], ` D --  lookup  ATTRS={"id":7,"request_id":"r-1"}`)
+	tt.Regexp(` W --  GET /users/7 404 \d.*s  request  ATTRS=\{`, lines[1])
+	attrs := map[string]any{}
+	tt.NoError(json.Unmarshal([]byte(lines[1][strings.Index(lines[1], mlog.AttrsJSONprefix)+len(mlog.AttrsJSONprefix):]), &attrs))
+	tt.EqualValues(map[string]any{
+		"bytes":       4.0,
+		"remote_addr": "192.0.2.1:1234",
+		"request_id":  "r-1",
+		"route":       "/users/{id}",
+		"user_agent":  "test/1.0",
+	}, attrs)
+
+	buf.Reset()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a%0A2030-01-01T00:00:00.000000Z%20E%20--%20forged", nil))
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	tt.Len(lines, 2, "the path can't forge a line")
+	tt.Contains(lines[1], ` W --  GET "/a\n2030-01-01T00:00:00.000000Z E -- forged" 404 `)
+}
+
+func Test__AccessLog__Panic(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+	h := mlog.AccessLogMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}), &mlog.AccessLogOptions{Logger: logger})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	tt.EqualValues(http.StatusInternalServerError, rec.Code)
+	tt.Len(rec.Header().Get(mlog.DefaultRequestIDHeader), 16)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	tt.Len(lines, 2)
+	var panicRecord, accessRecord map[string]any
+	tt.NoError(json.Unmarshal([]byte(lines[0]), &panicRecord))
+	tt.NoError(json.Unmarshal([]byte(lines[1]), &accessRecord))
+
+	tt.EqualValues("ERROR", panicRecord["level"])
+	tt.EqualValues("boom", panicRecord[mlog.ErrorKey])
+	stack, ok := panicRecord[mlog.StackKey].([]any)
+	tt.True(ok)
+	tt.Contains(stack[0], "Test__AccessLog__Panic.func1")
+
+	tt.EqualValues("ERROR", accessRecord["level"])
+	tt.EqualValues(500.0, accessRecord["status"])
+	tt.EqualValues("POST", accessRecord["method"])
+	tt.EqualValues(panicRecord[mlog.RequestIDKey], accessRecord[mlog.RequestIDKey])
+
+	buf.Reset()
+	h = mlog.AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("partial")) //nolint:errcheck
+		panic("boom")
+	}), &mlog.AccessLogOptions{Logger: logger})
+	tt.PanicsWithValue(http.ErrAbortHandler, func() { // the started response is aborted
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	tt.Len(lines, 2)
+	tt.Contains(lines[1], `"level":"ERROR","msg":"request","request_id":`)
+	tt.Contains(lines[1], `"status":500`)
+}
+
+func Test__AccessLog__Skip(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	h := mlog.AccessLogMiddleware(http.NotFoundHandler(), &mlog.AccessLogOptions{
+		Logger: slog.New(slog.NewJSONHandler(buf, nil)),
+		Skip:   func(r *http.Request) bool { return r.URL.Path == "/healthz" },
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	tt.Empty(buf.String())
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/other", nil))
+	tt.Contains(buf.String(), `"level":"WARN","msg":"request","request_id":`)
+}
+
+func Test__AccessLog__Hijack(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	h := mlog.AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/file" {
+			_, err := io.Copy(w, strings.NewReader("content"))
+			tt.NoError(err)
+			return
+		}
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+	}), &mlog.AccessLogOptions{Logger: slog.New(slog.NewJSONHandler(buf, nil))})
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		h.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	tt.NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+	tt.NoError(err)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	tt.NoError(err)
+	tt.EqualValues(http.StatusSwitchingProtocols, resp.StatusCode)
+	<-done
+	tt.Contains(buf.String(), `"status":101`)
+
+	buf.Reset()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/ws", nil)) // the recorder can't be hijacked
+	tt.EqualValues(http.StatusNotImplemented, rec.Code)
+
+	buf.Reset()
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/file", nil))
+	tt.EqualValues("content", rec.Body.String())
+	tt.Contains(buf.String(), `"status":200,"bytes":7`)
+}
@@ -0,0 +1,276 @@
+package mlog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type BatchOptions struct {
+	// MaxBatchSize limits the number of records in one request. If zero, [DefaultMaxBatchSize] is used.
+	MaxBatchSize int
+	// FlushInterval is the maximum time a record waits in the queue before it is sent.
+	// If zero, [DefaultFlushInterval] is used.
+	FlushInterval time.Duration
+	// QueueSize limits the number of queued records, new records are dropped when the queue is full.
+	// If zero, [DefaultQueueSize] is used.
+	QueueSize int
+	// BlockTimeout enables backpressure: when the queue is full, Handle waits up to BlockTimeout
+	// for the free space before dropping the record. If zero, records are dropped immediately.
+	BlockTimeout time.Duration
+
+	// MaxRetries is the number of attempts to resend the failed batch, the batch is dropped after them.
+	// If zero, [DefaultMaxRetries] is used, negative value disables retries.
+	MaxRetries int
+	// MinBackoff and MaxBackoff limit the exponentially growing delay between retries.
+	// If zero, [DefaultMinBackoff] and [DefaultMaxBackoff] are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// CloseTimeout limits time to send queued records at Close. If zero, [DefaultCloseTimeout] is used.
+	CloseTimeout time.Duration
+}
+
+// permanentError is a send error, which can not be fixed by retry, like HTTP 400.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// partialError is a send error, which means that only some items of the batch were failed:
+// lost items are rejected permanently, retry items should be sent again.
+type partialError[T any] struct {
+	err   error
+	lost  int
+	retry []T
+}
+
+func (e partialError[T]) Error() string {
+	return e.err.Error()
+}
+
+func (e partialError[T]) Unwrap() error {
+	return e.err
+}
+
+// batcher collects items into batches and sends them by the background goroutine,
+// retrying failed batches with exponential backoff. It is shared by all handlers,
+// derived by WithAttrs and WithGroup.
+type batcher[T any] struct {
+	opts BatchOptions
+	send func(ctx context.Context, batch []T) error
+
+	items   chan T
+	flushes chan chan error
+	closing chan struct{}
+	done    chan struct{}
+	closed  atomic.Bool
+	once    sync.Once
+	mu      sync.RWMutex // add holds read lock, so close waits for items being queued
+
+	ctx    context.Context //nolint:containedctx // cancels sending after CloseTimeout
+	cancel context.CancelFunc
+
+	dropped  atomic.Uint64
+	failures atomic.Uint64
+}
+
+func newBatcher[T any](opts BatchOptions, send func(ctx context.Context, batch []T) error) *batcher[T] {
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(DefaultMaxBackoff, opts.MinBackoff)
+	}
+	if opts.CloseTimeout <= 0 {
+		opts.CloseTimeout = DefaultCloseTimeout
+	}
+	b := &batcher[T]{
+		opts:    opts,
+		send:    send,
+		items:   make(chan T, opts.QueueSize),
+		flushes: make(chan chan error),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	go b.run()
+	return b
+}
+
+// add queues the item. If the queue is full, it waits up to BlockTimeout and returns [ErrBufferFull].
+func (b *batcher[T]) add(item T) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed.Load() {
+		b.dropped.Add(1)
+		return ErrClosed
+	}
+	select {
+	case b.items <- item:
+		return nil
+	default:
+	}
+	if b.opts.BlockTimeout > 0 {
+		timer := time.NewTimer(b.opts.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case b.items <- item:
+			return nil
+		case <-timer.C:
+		case <-b.closing:
+		}
+	}
+	b.dropped.Add(1)
+	return ErrBufferFull
+}
+
+// flush sends all queued items and returns the error of the last failed batch.
+func (b *batcher[T]) flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case b.flushes <- reply:
+	case <-b.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck
+	}
+}
+
+// close sends queued items during CloseTimeout and stops the background goroutine.
+// Later calls do nothing, so handlers, sharing the batcher, may be closed one by one.
+func (b *batcher[T]) close() error {
+	var err error
+	b.once.Do(func() {
+		b.closed.Store(true)
+		close(b.closing)
+		// wait for add calls in progress, so each item is either queued before the drain
+		// or counted as dropped below; later calls see the closed flag
+		b.mu.Lock()
+		b.mu.Unlock() //nolint:staticcheck // empty critical section is the barrier
+		timer := time.AfterFunc(b.opts.CloseTimeout, b.cancel)
+		defer timer.Stop()
+		<-b.done
+		b.cancel()
+		if n := len(b.items); n != 0 {
+			b.dropped.Add(uint64(n))
+			err = ErrBufferFull
+		}
+	})
+	return err
+}
+
+func (b *batcher[T]) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+	var batch []T
+	for {
+		select {
+		case item := <-b.items:
+			batch = append(batch, item)
+			if len(batch) >= b.opts.MaxBatchSize {
+				b.sendBatch(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) != 0 {
+				b.sendBatch(batch)
+				batch = nil
+			}
+		case reply := <-b.flushes:
+			reply <- b.drain(batch)
+			batch = nil
+		case <-b.closing:
+			b.drain(batch)
+			return
+		}
+	}
+}
+
+// drain sends the batch and all queued items.
+func (b *batcher[T]) drain(batch []T) error {
+	var err error
+	for {
+		select {
+		case item := <-b.items:
+			batch = append(batch, item)
+			if len(batch) < b.opts.MaxBatchSize {
+				continue
+			}
+		default:
+		}
+		if len(batch) == 0 {
+			return err
+		}
+		if e := b.sendBatch(batch); e != nil {
+			err = e
+		}
+		batch = nil
+		if b.ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// sendBatch sends the batch, retrying it with exponential backoff.
+// The error is returned if some items were not sent.
+func (b *batcher[T]) sendBatch(batch []T) error {
+	var lostErr error // error of the items, dropped by previous attempts
+	backoff := b.opts.MinBackoff
+	for attempt := 0; ; attempt++ {
+		err := b.send(b.ctx, batch)
+		if err == nil {
+			return lostErr
+		}
+		b.failures.Add(1)
+		var partErr partialError[T]
+		if errors.As(err, &partErr) {
+			if partErr.lost != 0 {
+				b.dropped.Add(uint64(partErr.lost))
+				lostErr = err
+			}
+			batch = partErr.retry
+			if len(batch) == 0 {
+				return err
+			}
+		}
+		var permErr permanentError
+		if errors.As(err, &permErr) || attempt >= b.opts.MaxRetries || b.ctx.Err() != nil {
+			b.dropped.Add(uint64(len(batch)))
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-b.ctx.Done():
+		}
+		backoff = min(2*backoff, b.opts.MaxBackoff) //nolint:gomnd
+	}
+}
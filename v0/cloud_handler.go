@@ -0,0 +1,247 @@
+package mlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CloudProfile selects the JSON conventions of the cloud provider.
+type CloudProfile int
+
+const (
+	// CloudProfileGCP follows Google Cloud Logging structured logging conventions.
+	CloudProfileGCP CloudProfile = iota
+	// CloudProfileAWS follows AWS Lambda JSON log format and CloudWatch Embedded Metric Format.
+	CloudProfileAWS
+)
+
+type CloudHandlerOptions struct {
+	// Profile selects the provider conventions, GCP by default.
+	Profile CloudProfile
+
+	// ProjectID is the GCP project ID, used to build "logging.googleapis.com/trace" field
+	// as "projects/PROJECT_ID/traces/TRACE_ID". If empty, the bare trace ID is used.
+	ProjectID string
+
+	// MetricNamespace is the CloudWatch namespace of metrics, see [Metric].
+	// If empty, [DefaultMetricNamespace] is used.
+	MetricNamespace string
+	// MetricDimensions are keys of the record attributes, which are used as CloudWatch dimensions of metrics.
+	MetricDimensions []string
+
+	// AddSource causes the handler to add the source code position of the log statement.
+	AddSource bool
+
+	// StackTraceLevel enables stack trace for records with level at or above given,
+	// see [HumanReadableHandlerOptions.StackTraceLevel]. If nil, stack traces are not shown.
+	StackTraceLevel slog.Leveler
+
+	// MaxAttrDepth and MaxAttrSize limit attribute values, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to log.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelInfo].
+	Level slog.Leveler
+}
+
+// CloudHandler is a [slog.Handler] that writes Records to an [io.Writer] (usually stdout) as JSON lines,
+// which are recognized by the log agent of the managed cloud platform.
+//
+// With [CloudProfileGCP] it writes "severity", "message", "time", "logging.googleapis.com/sourceLocation",
+// "logging.googleapis.com/trace" and "logging.googleapis.com/spanId" fields.
+// With [CloudProfileAWS] it writes "timestamp", "level", "message", "traceId" in X-Ray format,
+// and records with [Metric] attributes are written in CloudWatch Embedded Metric Format.
+// Attributes are written as top-level fields in both cases.
+type CloudHandler struct {
+	opts  CloudHandlerOptions
+	state handlerState
+	mu    *sync.Mutex
+	out   io.Writer
+}
+
+// NewCloudHandler creates a CloudHandler that writes to w, using the given options.
+// If opts is nil, the default options are used.
+func NewCloudHandler(w io.Writer, opts *CloudHandlerOptions) *CloudHandler {
+	h := &CloudHandler{
+		out: w,
+		mu:  &sync.Mutex{},
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	if h.opts.MetricNamespace == "" {
+		h.opts.MetricNamespace = DefaultMetricNamespace
+	}
+	h.state = newHandlerState(newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize))
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *CloudHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle writes the Record as one JSON line.
+// Implements [slog.Handler] interface.
+func (h *CloudHandler) Handle(ctx context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	entry, _ := h.state.tree(&r) // provider fields are set after attributes and take precedence
+	var stack []uintptr
+	if h.opts.StackTraceLevel != nil && r.Level >= h.opts.StackTraceLevel.Level() {
+		stack = recordStack(&r)
+	}
+	if h.opts.Profile == CloudProfileAWS {
+		h.awsFields(ctx, &r, entry, stack)
+	} else {
+		h.gcpFields(ctx, &r, entry, stack)
+	}
+
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Join(Error, err)
+	}
+	buf = append(buf, '\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := h.out.Write(buf); err != nil {
+		return errors.Join(Error, err)
+	}
+	return nil
+}
+
+func (h *CloudHandler) gcpFields(ctx context.Context, r *slog.Record, entry jsonTree, stack []uintptr) {
+	entry["severity"] = LevelToSeverity(r.Level).String()
+	entry["message"] = r.Message
+	entry["time"] = r.Time.UTC().Format(time.RFC3339Nano)
+	if h.opts.AddSource && r.PC != 0 {
+		source := DecodeSource(r.PC)
+		entry[gcpSourceLocationKey] = jsonTree{
+			"file":     source.File,
+			"line":     strconv.Itoa(source.Line),
+			"function": source.Function,
+		}
+	}
+	if len(stack) != 0 {
+		entry["stack_trace"] = string(appendStack(nil, stack)) // recognized by Error Reporting
+	}
+	if tc, ok := TraceFromContext(ctx); ok {
+		trace := tc.TraceIDString()
+		if h.opts.ProjectID != "" {
+			trace = "projects/" + h.opts.ProjectID + "/traces/" + trace
+		}
+		entry[gcpTraceKey] = trace
+		entry[gcpSpanIDKey] = tc.SpanIDString()
+		entry[gcpTraceSampledKey] = tc.Sampled()
+	}
+}
+
+func (h *CloudHandler) awsFields(ctx context.Context, r *slog.Record, entry jsonTree, stack []uintptr) {
+	entry["timestamp"] = r.Time.UTC().Format(time.RFC3339Nano)
+	entry["level"] = LevelString(r.Level)
+	entry["message"] = r.Message
+	if h.opts.AddSource && r.PC != 0 {
+		source := DecodeSource(r.PC)
+		entry[slog.SourceKey] = jsonTree{
+			"file":     source.File,
+			"line":     int64(source.Line),
+			"function": source.Function,
+		}
+	}
+	if len(stack) != 0 {
+		entry["stackTrace"] = formatStack(stack)
+	}
+	if tc, ok := TraceFromContext(ctx); ok {
+		entry["traceId"] = xrayTraceID(tc)
+		entry["spanId"] = tc.SpanIDString()
+	}
+	if len(h.state.groups) == 1 { // metrics are possible at the top level only
+		h.addEMF(r, entry)
+	}
+}
+
+// addEMF adds CloudWatch Embedded Metric Format metadata, if the record has [Metric] attributes.
+// Values of metrics are already in the entry, because [MetricValue] is resolved to the number.
+func (h *CloudHandler) addEMF(r *slog.Record, entry jsonTree) {
+	metrics := []jsonTree{}
+	r.Attrs(func(a slog.Attr) bool {
+		if m, ok := a.Value.Any().(MetricValue); ok && a.Value.Kind() == slog.KindLogValuer {
+			metric := jsonTree{"Name": a.Key}
+			if m.Unit != "" {
+				metric["Unit"] = m.Unit
+			}
+			metrics = append(metrics, metric)
+		}
+		return true
+	})
+	if len(metrics) == 0 {
+		return
+	}
+	dimensions := []string{}
+	for _, k := range h.opts.MetricDimensions {
+		if v, ok := entry[k]; ok {
+			entry[k] = formatScalar(v) // dimension values must be strings
+			dimensions = append(dimensions, k)
+		}
+	}
+	entry["_aws"] = jsonTree{
+		"Timestamp": r.Time.UnixMilli(),
+		"CloudWatchMetrics": []jsonTree{{
+			"Namespace":  h.opts.MetricNamespace,
+			"Dimensions": [][]string{dimensions},
+			"Metrics":    metrics,
+		}},
+	}
+}
+
+// WithAttrs returns a new CloudHandler whose attributes consists of h's attributes followed by attrs.
+// Implements [slog.Handler] interface.
+func (h *CloudHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &CloudHandler{
+		opts:  h.opts,
+		state: h.state.withAttrs(aa),
+		mu:    h.mu,
+		out:   h.out,
+	}
+}
+
+// WithGroup returns a new CloudHandler with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *CloudHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &CloudHandler{
+		opts:  h.opts,
+		state: h.state.withGroup(name),
+		mu:    h.mu,
+		out:   h.out,
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// xrayTraceID returns the trace ID in AWS X-Ray format: "1-" + 8 hex digits + "-" + 24 hex digits.
+func xrayTraceID(tc TraceContext) string {
+	id := tc.TraceIDString()
+	return "1-" + id[:8] + "-" + id[8:]
+}
@@ -0,0 +1,87 @@
+package mlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__CloudHandler__GCP(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(mlog.NewCloudHandler(buf, &mlog.CloudHandlerOptions{
+		ProjectID:       "proj",
+		AddSource:       true,
+		StackTraceLevel: slog.LevelError,
+	}))
+	ctx, err := mlog.WithTraceParent(context.Background(), traceParent)
+	tt.NoError(err)
+
+	logger.With("a", 1).WarnContext(ctx, "hello gcp", "severity", "overridden")
+	logger.Error("failed")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	tt.Len(lines, 2)
+	data := map[string]any{}
+	tt.NoError(json.Unmarshal(lines[0], &data))
+	tt.EqualValues("WARNING", data["severity"])
+	tt.EqualValues("hello gcp", data["message"])
+	tt.EqualValues(1, data["a"])
+	tt.EqualValues("projects/proj/traces/4bf92f3577b34da6a3ce929d0e0e4736", data["logging.googleapis.com/trace"])
+	tt.EqualValues("00f067aa0ba902b7", data["logging.googleapis.com/spanId"])
+	tt.EqualValues(true, data["logging.googleapis.com/trace_sampled"])
+	tt.Contains(JqMust(t, data, `."logging.googleapis.com/sourceLocation".function`), "Test__CloudHandler__GCP")
+	tt.NotContains(data, "stack_trace")
+
+	data = map[string]any{}
+	tt.NoError(json.Unmarshal(lines[1], &data))
+	tt.EqualValues("ERROR", data["severity"])
+	tt.Contains(data["stack_trace"], "Test__CloudHandler__GCP")
+	tt.NotContains(data, "logging.googleapis.com/trace")
+}
+
+func Test__CloudHandler__AWS_EMF(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(mlog.NewCloudHandler(buf, &mlog.CloudHandlerOptions{
+		Profile:          mlog.CloudProfileAWS,
+		MetricNamespace:  "app",
+		MetricDimensions: []string{"route", "missing"},
+	}))
+	ctx, err := mlog.WithTraceParent(context.Background(), traceParent)
+	tt.NoError(err)
+
+	logger.InfoContext(ctx, "request done", "route", "/x", mlog.Metric("latency", 12.5, "Milliseconds"), mlog.Metric("hits", 1, ""))
+	logger.Info("plain")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	tt.Len(lines, 2)
+	data := map[string]any{}
+	tt.NoError(json.Unmarshal(lines[0], &data))
+	tt.EqualValues("INFO", data["level"])
+	tt.EqualValues("request done", data["message"])
+	tt.EqualValues("1-4bf92f35-77b34da6a3ce929d0e0e4736", data["traceId"])
+	tt.EqualValues(12.5, data["latency"])
+	tt.EqualValues(1, data["hits"])
+	tt.EqualValues("app", JqMust(t, data, `._aws.CloudWatchMetrics[0].Namespace`))
+	tt.EqualValues([]any{[]any{"route"}}, JqMust(t, data, `._aws.CloudWatchMetrics[0].Dimensions`))
+	tt.EqualValues(
+		[]any{map[string]any{"Name": "latency", "Unit": "Milliseconds"}, map[string]any{"Name": "hits"}},
+		JqMust(t, data, `._aws.CloudWatchMetrics[0].Metrics`))
+
+	data = map[string]any{}
+	tt.NoError(json.Unmarshal(lines[1], &data))
+	tt.NotContains(data, "_aws")
+
+	// other handlers render metrics as numbers
+	hrBuf := &bytes.Buffer{}
+	slog.New(mlog.NewHumanReadableHandler(hrBuf, nil)).Info("metric", mlog.Metric("latency", 12.5, "Milliseconds"))
+	tt.EqualValues(12.5, hrAttrs(t, hrBuf)["latency"])
+}
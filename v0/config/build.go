@@ -0,0 +1,328 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+const fileMode = 0o644
+
+var facilities = map[string]mlog.Facility{ //nolint:gochecknoglobals
+	"kern": mlog.FacilityKern, "user": mlog.FacilityUser, "mail": mlog.FacilityMail, "daemon": mlog.FacilityDaemon,
+	"auth": mlog.FacilityAuth, "syslog": mlog.FacilitySyslog, "lpr": mlog.FacilityLpr, "news": mlog.FacilityNews,
+	"uucp": mlog.FacilityUucp, "cron": mlog.FacilityCron, "authpriv": mlog.FacilityAuthPriv, "ftp": mlog.FacilityFtp,
+	"local0": mlog.FacilityLocal0, "local1": mlog.FacilityLocal1, "local2": mlog.FacilityLocal2, "local3": mlog.FacilityLocal3,
+	"local4": mlog.FacilityLocal4, "local5": mlog.FacilityLocal5, "local6": mlog.FacilityLocal6, "local7": mlog.FacilityLocal7,
+}
+
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Tree is the handler tree, built from the [Config]. It is a [slog.Handler] itself,
+// and owns files, connections and background goroutines of its handlers, which are released by Close.
+type Tree struct {
+	slog.Handler
+	flushers []flusher
+	closers  []io.Closer
+	writers  map[string]*mlog.NetWriter // by network, address and spool directory, see writerKey
+	prev     *Tree                      // the tree, whose network writers are reused while building
+}
+
+// Build creates handlers of the configuration. A single handler is used as is,
+// several handlers are combined by [mlog.MultipleHandler].
+func (c *Config) Build() (*Tree, error) {
+	return c.build(nil)
+}
+
+// build creates handlers of the configuration, reusing network writers of prev with the same
+// network, address and spool directory, so two writers never share the spool directory.
+// After the new tree is applied, shared writers must be removed from prev by [Tree.disown].
+func (c *Config) build(prev *Tree) (*Tree, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	level, _ := parseLevel(c.Level, slog.LevelInfo)
+	t := &Tree{writers: map[string]*mlog.NetWriter{}, prev: prev}
+	defer func() { t.prev = nil }()
+	handlers := make([]slog.Handler, 0, len(c.Handlers))
+	for i := range c.Handlers {
+		h, err := t.build(&c.Handlers[i], level)
+		if err != nil {
+			t.disown(prev) // writers of prev are still used by it
+			t.Close()      //nolint:errcheck,gosec
+			return nil, &FieldError{Path: fmt.Sprintf("handlers[%d]", i), Err: err}
+		}
+		handlers = append(handlers, h)
+	}
+	if len(handlers) == 1 {
+		t.Handler = handlers[0]
+	} else {
+		t.Handler = mlog.NewMultipleHandler(nil, handlers...)
+	}
+	return t, nil
+}
+
+// Flush sends records, buffered by handlers and network writers of the tree.
+func (t *Tree) Flush(ctx context.Context) error {
+	var errs []error
+	for _, f := range t.flushers {
+		errs = append(errs, f.Flush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops handlers of the tree, sending buffered records, and closes their files and connections.
+// The tree must not be used after Close.
+func (t *Tree) Close() error {
+	var errs []error
+	for i := len(t.closers) - 1; i >= 0; i-- { // handlers are closed before their writers
+		errs = append(errs, t.closers[i].Close())
+	}
+	t.closers = nil
+	return errors.Join(errs...)
+}
+
+// disown removes network writers, which are used by other tree too, from resources of t,
+// so they are not flushed and closed with t.
+func (t *Tree) disown(other *Tree) {
+	if other == nil {
+		return
+	}
+	shared := func(v any) bool {
+		w, ok := v.(*mlog.NetWriter)
+		if !ok {
+			return false
+		}
+		for _, ow := range other.writers {
+			if ow == w {
+				return true
+			}
+		}
+		return false
+	}
+	t.flushers = slices.DeleteFunc(t.flushers, func(f flusher) bool { return shared(f) })
+	t.closers = slices.DeleteFunc(t.closers, func(c io.Closer) bool { return shared(c) })
+	for key, w := range t.writers {
+		if shared(w) {
+			delete(t.writers, key)
+		}
+	}
+}
+
+// own registers resources of v to be flushed and closed with the tree.
+func (t *Tree) own(v any) {
+	if f, ok := v.(flusher); ok {
+		t.flushers = append(t.flushers, f)
+	}
+	if c, ok := v.(io.Closer); ok && v != os.Stdout && v != os.Stderr {
+		t.closers = append(t.closers, c)
+	}
+}
+
+func (t *Tree) build(hc *HandlerConfig, defLevel slog.Level) (slog.Handler, error) {
+	level, _ := parseLevel(hc.Level, defLevel)
+	var h slog.Handler
+	switch hc.Type {
+	case TypeHuman, TypeJSON, TypeText, TypeCloud:
+		w, err := t.writer(hc)
+		if err != nil {
+			return nil, err
+		}
+		h = lineHandler(hc, w, level)
+	case TypeSyslog:
+		format := mlog.SyslogRFC5424
+		if hc.Format == "rfc3164" {
+			format = mlog.SyslogRFC3164
+		}
+		h = mlog.NewSyslogHandler(&mlog.SyslogHandlerOptions{
+			Network:   hc.Network,
+			Addr:      hc.Addr,
+			Format:    format,
+			Facility:  facilities[hc.Facility],
+			AppName:   hc.AppName,
+			AddSource: hc.AddSource,
+			Level:     level,
+		})
+	case TypeGELF:
+		h = mlog.NewGELFHandler(&mlog.GELFHandlerOptions{
+			Network:   hc.Network,
+			Addr:      hc.Addr,
+			AddSource: hc.AddSource,
+			Level:     level,
+		})
+	case TypeOTLP:
+		protocol := mlog.OTLPProtocolHTTPProtobuf
+		if hc.Format == "json" {
+			protocol = mlog.OTLPProtocolHTTPJSON
+		}
+		h = mlog.NewOTLPHandler(&mlog.OTLPHandlerOptions{
+			Endpoint:    hc.URL,
+			Protocol:    protocol,
+			Headers:     hc.Headers,
+			ServiceName: hc.ServiceName,
+			AddSource:   hc.AddSource,
+			Level:       level,
+		})
+	case TypeLoki:
+		format := mlog.LokiFormatHumanReadable
+		if hc.Format == "json" {
+			format = mlog.LokiFormatJSON
+		}
+		h = mlog.NewLokiHandler(&mlog.LokiHandlerOptions{
+			Endpoint:   hc.URL,
+			Headers:    hc.Headers,
+			Labels:     hc.Labels,
+			LabelAttrs: hc.LabelAttrs,
+			Format:     format,
+			AddSource:  hc.AddSource,
+			Level:      level,
+		})
+	case TypeElastic:
+		h = mlog.NewElasticHandler(&mlog.ElasticHandlerOptions{
+			Endpoint:    hc.URL,
+			Index:       hc.Index,
+			Headers:     hc.Headers,
+			ServiceName: hc.ServiceName,
+			AddSource:   hc.AddSource,
+			Level:       level,
+		})
+	case TypeWebhook:
+		format := mlog.WebhookFormatSlack
+		if hc.Format == "generic" {
+			format = mlog.WebhookFormatGeneric
+		}
+		h = mlog.NewWebhookHandler(&mlog.WebhookHandlerOptions{
+			URL:     hc.URL,
+			Format:  format,
+			Headers: hc.Headers,
+			Level:   level,
+		})
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownValue, hc.Type)
+	}
+	t.own(h)
+	if hc.Filter != nil {
+		h = mlog.NewFilterHandler(h, hc.Filter.match())
+	}
+	return h, nil
+}
+
+// writer returns the output of the line handler.
+func (t *Tree) writer(hc *HandlerConfig) (io.Writer, error) {
+	if hc.Addr != "" {
+		network := hc.Network
+		if network == "" {
+			network = "tcp"
+		}
+		opts := &mlog.NetWriterOptions{Network: network, Addr: hc.Addr, SpoolDir: hc.Spool}
+		key := netWriterKey(opts)
+		if w, ok := t.writers[key]; ok {
+			return w, nil
+		}
+		var w *mlog.NetWriter
+		if t.prev != nil {
+			w = t.prev.writers[key]
+		}
+		if w == nil {
+			var err error
+			if w, err = mlog.NewNetWriter(opts); err != nil {
+				return nil, err //nolint:wrapcheck
+			}
+		}
+		t.writers[key] = w
+		t.own(w)
+		return w, nil
+	}
+	switch hc.Output {
+	case "", "stderr":
+		return os.Stderr, nil
+	case "stdout":
+		return os.Stdout, nil
+	}
+	f, err := os.OpenFile(hc.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, fileMode)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	t.own(f)
+	return f, nil
+}
+
+// lineHandler creates the handler, which writes lines to w. Handlers of the standard library are wrapped
+// by [mlog.LevelHandler] to respect the level override of the context and write names of mlog levels.
+func lineHandler(hc *HandlerConfig, w io.Writer, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{AddSource: hc.AddSource, Level: level, ReplaceAttr: mlog.ReplaceLevelName}
+	switch hc.Type {
+	case TypeJSON:
+		return mlog.NewLevelHandler(nil, slog.NewJSONHandler(w, opts))
+	case TypeText:
+		return mlog.NewLevelHandler(nil, slog.NewTextHandler(w, opts))
+	case TypeCloud:
+		profile := mlog.CloudProfileGCP
+		if hc.Format == "aws" {
+			profile = mlog.CloudProfileAWS
+		}
+		return mlog.NewCloudHandler(w, &mlog.CloudHandlerOptions{Profile: profile, AddSource: hc.AddSource, Level: level})
+	}
+	return mlog.NewHumanReadableHandler(w, &mlog.HumanReadableHandlerOptions{AddSource: hc.AddSource, Level: level})
+}
+
+// match returns the filter function of [mlog.FilterHandler]. The configuration must be validated.
+func (fc *FilterConfig) match() func(ctx context.Context, r slog.Record) bool {
+	include := make([]*regexp.Regexp, 0, len(fc.Messages))
+	for _, expr := range fc.Messages {
+		include = append(include, regexp.MustCompile(expr))
+	}
+	exclude := make([]*regexp.Regexp, 0, len(fc.ExcludeMessages))
+	for _, expr := range fc.ExcludeMessages {
+		exclude = append(exclude, regexp.MustCompile(expr))
+	}
+	maxLevel, _ := parseLevel(fc.MaxLevel, slog.Level(math.MaxInt))
+
+	return func(_ context.Context, r slog.Record) bool {
+		if r.Level > maxLevel {
+			return false
+		}
+		if len(include) != 0 && !matchAny(include, r.Message) {
+			return false
+		}
+		if matchAny(exclude, r.Message) {
+			return false
+		}
+		if len(fc.Attrs) == 0 {
+			return true
+		}
+		found := make(map[string]bool, len(fc.Attrs))
+		r.Attrs(func(a slog.Attr) bool {
+			if v, ok := fc.Attrs[a.Key]; ok && v == a.Value.Resolve().String() {
+				found[a.Key] = true
+			}
+			return len(found) < len(fc.Attrs)
+		})
+		return len(found) == len(fc.Attrs)
+	}
+}
+
+func matchAny(list []*regexp.Regexp, s string) bool {
+	for _, re := range list {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// netWriterKey identifies network writers, which can be shared: they send to the same address and use
+// the same spool directory, which can't be opened twice.
+func netWriterKey(opts *mlog.NetWriterOptions) string {
+	return opts.Network + " " + opts.Addr + " " + opts.SpoolDir
+}
@@ -0,0 +1,256 @@
+/*
+Package config builds [mlog] handler trees from the declarative configuration,
+so logging can be changed by editing a file or environment variables, without a rebuild.
+
+The configuration is a YAML document (JSON documents are accepted too, because JSON is a subset of YAML):
+
+	level: info
+	handlers:
+	  - type: human
+	    output: stderr
+	  - type: json
+	    level: debug
+	    output: /var/log/app/debug.log
+	    filter:
+	      max_level: debug
+	  - type: loki
+	    url: http://loki:3100
+	    labels: {job: api}
+	    label_attrs: [component]
+
+Each handler has a type: "human", "json", "text" and "cloud" write lines to the output (stdout, stderr
+or a file path) or, if addr is set, to the network collector through [mlog.NetWriter];
+"syslog", "gelf", "otlp", "loki", "elastic" and "webhook" send records by their own protocols.
+
+Errors of [Parse] and [Config.Validate] point to the offending value, like "handlers[1].level: unknown level".
+*/
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+
+	mlog "github.com/xenolog/mlog/v0"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrRequired     = errors.New("value is required")
+	ErrUnknownValue = errors.New("unknown value")
+	ErrConflict     = errors.New("conflicting values")
+)
+
+// Handler types.
+const (
+	TypeHuman   = "human"
+	TypeJSON    = "json"
+	TypeText    = "text"
+	TypeCloud   = "cloud"
+	TypeSyslog  = "syslog"
+	TypeGELF    = "gelf"
+	TypeOTLP    = "otlp"
+	TypeLoki    = "loki"
+	TypeElastic = "elastic"
+	TypeWebhook = "webhook"
+)
+
+// Config is the logging configuration.
+type Config struct {
+	// Level is the default minimum level of handlers, like "debug" or "warn+2". If empty, "info" is used.
+	Level string `yaml:"level"`
+	// Handlers are destinations of records, each record is passed to all of them.
+	Handlers []HandlerConfig `yaml:"handlers"`
+}
+
+// HandlerConfig configures one handler of the tree. Fields, which are not used by the handler type, are ignored.
+type HandlerConfig struct {
+	// Type is the handler type, one of Type* constants.
+	Type string `yaml:"type"`
+	// Level is the minimum level of the handler. If empty, [Config.Level] is used.
+	Level string `yaml:"level"`
+	// AddSource adds the source code position of the log statement.
+	AddSource bool `yaml:"add_source"`
+	// Filter passes to the handler only the matching records.
+	Filter *FilterConfig `yaml:"filter"`
+
+	// Output is "stdout", "stderr" or a file path for human, json, text and cloud handlers.
+	// If empty, "stderr" is used.
+	Output string `yaml:"output"`
+	// Network and Addr of the collector for human, json, text and cloud handlers (then Output must be empty),
+	// and syslog and gelf handlers. If Network is empty, "tcp" is used for lines, see handler options otherwise.
+	Network string `yaml:"network"`
+	Addr    string `yaml:"addr"`
+	// Spool is the spool directory of the [mlog.NetWriter], see [mlog.NetWriterOptions.SpoolDir].
+	Spool string `yaml:"spool"`
+
+	// URL is the endpoint of otlp, loki, elastic and webhook handlers.
+	URL string `yaml:"url"`
+	// Headers are added to each request of otlp, loki, elastic and webhook handlers.
+	Headers map[string]string `yaml:"headers"`
+
+	// Format is "rfc5424" or "rfc3164" for syslog, "protobuf" or "json" for otlp,
+	// "human" or "json" for loki, "slack" or "generic" for webhook, "gcp" or "aws" for cloud handler.
+	Format string `yaml:"format"`
+	// AppName and Facility (like "user" or "local0") of syslog messages.
+	AppName  string `yaml:"app_name"`
+	Facility string `yaml:"facility"`
+	// ServiceName is the service name of otlp and elastic handlers.
+	ServiceName string `yaml:"service_name"`
+	// Labels and LabelAttrs of loki streams, see [mlog.LokiHandlerOptions].
+	Labels     map[string]string `yaml:"labels"`
+	LabelAttrs []string          `yaml:"label_attrs"`
+	// Index is the elastic index prefix.
+	Index string `yaml:"index"`
+}
+
+// FilterConfig selects records, passed to the handler. All given conditions must match.
+type FilterConfig struct {
+	// Messages are regular expressions, the message must match one of them.
+	Messages []string `yaml:"messages"`
+	// ExcludeMessages are regular expressions, the message must match none of them.
+	ExcludeMessages []string `yaml:"exclude_messages"`
+	// Attrs are required values of the top-level attributes.
+	Attrs map[string]string `yaml:"attrs"`
+	// MaxLevel is the maximum level of records, for example to keep only debug records in the file.
+	MaxLevel string `yaml:"max_level"`
+}
+
+// FieldError is the error of the configuration value, Path is the value path like "handlers[1].level".
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Parse parses and validates the YAML or JSON configuration. Unknown fields are errors.
+func Parse(data []byte) (*Config, error) {
+	c := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err //nolint:wrapcheck
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Load reads the configuration file, see [Parse].
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Validate checks the configuration and returns all found errors, joined by [errors.Join].
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(path string, err error) {
+		errs = append(errs, &FieldError{Path: path, Err: err})
+	}
+	if _, err := parseLevel(c.Level, slog.LevelInfo); err != nil {
+		add("level", err)
+	}
+	if len(c.Handlers) == 0 {
+		add("handlers", ErrRequired)
+	}
+	for i := range c.Handlers {
+		c.Handlers[i].validate(fmt.Sprintf("handlers[%d]", i), add)
+	}
+	return errors.Join(errs...)
+}
+
+func (hc *HandlerConfig) validate(path string, add func(path string, err error)) {
+	if _, err := parseLevel(hc.Level, slog.LevelInfo); err != nil {
+		add(path+".level", err)
+	}
+	if hc.Filter != nil {
+		hc.Filter.validate(path+".filter", add)
+	}
+	checkFormat := func(formats ...string) {
+		if hc.Format != "" && !slices.Contains(formats, hc.Format) {
+			add(path+".format", fmt.Errorf("%w %q", ErrUnknownValue, hc.Format))
+		}
+	}
+	switch hc.Type {
+	case TypeHuman, TypeJSON, TypeText, TypeCloud:
+		if hc.Output != "" && hc.Addr != "" {
+			add(path+".addr", fmt.Errorf("%w: output and addr", ErrConflict))
+		}
+		if hc.Type == TypeCloud {
+			checkFormat("gcp", "aws")
+		}
+	case TypeSyslog:
+		checkFormat("rfc5424", "rfc3164")
+		if _, ok := facilities[hc.Facility]; hc.Facility != "" && !ok {
+			add(path+".facility", fmt.Errorf("%w %q", ErrUnknownValue, hc.Facility))
+		}
+	case TypeGELF:
+		if hc.Addr == "" {
+			add(path+".addr", ErrRequired)
+		}
+	case TypeOTLP:
+		checkFormat("protobuf", "json")
+	case TypeLoki:
+		checkFormat("human", "json")
+	case TypeElastic:
+	case TypeWebhook:
+		checkFormat("slack", "generic")
+		if hc.URL == "" {
+			add(path+".url", ErrRequired)
+		}
+	case "":
+		add(path+".type", ErrRequired)
+	default:
+		add(path+".type", fmt.Errorf("%w %q", ErrUnknownValue, hc.Type))
+	}
+}
+
+func (fc *FilterConfig) validate(path string, add func(path string, err error)) {
+	for i, expr := range fc.Messages {
+		if _, err := regexp.Compile(expr); err != nil {
+			add(fmt.Sprintf("%s.messages[%d]", path, i), err)
+		}
+	}
+	for i, expr := range fc.ExcludeMessages {
+		if _, err := regexp.Compile(expr); err != nil {
+			add(fmt.Sprintf("%s.exclude_messages[%d]", path, i), err)
+		}
+	}
+	if _, err := parseLevel(fc.MaxLevel, slog.LevelInfo); err != nil {
+		add(path+".max_level", err)
+	}
+}
+
+// parseLevel parses level names like "debug", "trace" or "WARN+2", see [mlog.ParseLevel].
+// def is returned for the empty string.
+func parseLevel(s string, def slog.Level) (slog.Level, error) {
+	if s == "" {
+		return def, nil
+	}
+	l, err := mlog.ParseLevel(s)
+	if err != nil {
+		return def, fmt.Errorf("%w level %q", ErrUnknownValue, s)
+	}
+	return l, nil
+}
@@ -0,0 +1,135 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+	"github.com/xenolog/mlog/v0/config"
+)
+
+func Test__Config__Build(t *testing.T) {
+	tt := assert.New(t)
+
+	dir := t.TempDir()
+	c, err := config.Parse([]byte(`
+level: warn
+handlers:
+  - type: human
+    output: ` + filepath.Join(dir, "main.log") + `
+  - type: json
+    level: trace
+    output: ` + filepath.Join(dir, "debug.log") + `
+    filter:
+      max_level: debug
+      exclude_messages: ["^noisy"]
+  - type: text
+    level: info
+    output: ` + filepath.Join(dir, "db.log") + `
+    filter:
+      attrs: {component: db}
+`))
+	tt.NoError(err)
+	tree, err := c.Build()
+	tt.NoError(err)
+
+	logger := slog.New(tree)
+	logger.Debug("debug message")
+	logger.Log(context.Background(), mlog.LevelTrace, "trace message")
+	logger.Debug("noisy message")
+	logger.Info("info message")
+	logger.With("component", "db").Info("db message")
+	logger.Error("error message")
+	tt.NoError(tree.Flush(context.Background()))
+	tt.NoError(tree.Close())
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		tt.NoError(err)
+		return string(data)
+	}
+	main := read("main.log")
+	tt.EqualValues(1, strings.Count(main, "\n"))
+	tt.Contains(main, "error message")
+
+	debug := read("debug.log")
+	tt.EqualValues(2, strings.Count(debug, "\n"))
+	tt.Contains(debug, `"msg":"debug message"`)
+	tt.Contains(debug, `"level":"TRACE","msg":"trace message"`)
+
+	db := read("db.log")
+	tt.EqualValues(1, strings.Count(db, "\n"))
+	tt.Contains(db, `msg="db message" component=db`)
+}
+
+func Test__Config__Errors(t *testing.T) {
+	tt := assert.New(t)
+
+	_, err := config.Parse([]byte(`{
+		"level": "verbose",
+		"handlers": [
+			{"type": "human"},
+			{"type": "syslog", "facility": "local9", "format": "rfc3164"},
+			{"type": "webhook", "filter": {"messages": ["("]}},
+			{"level": "debug"}
+		]
+	}`))
+	tt.Error(err)
+	tt.ErrorIs(err, config.ErrUnknownValue)
+	tt.ErrorIs(err, config.ErrRequired)
+	var fe *config.FieldError
+	tt.True(errors.As(err, &fe))
+	tt.EqualValues("level", fe.Path)
+	for _, path := range []string{
+		"level: unknown value level \"verbose\"",
+		"handlers[1].facility: unknown value \"local9\"",
+		"handlers[2].filter.messages[0]: ",
+		"handlers[2].url: value is required",
+		"handlers[3].type: value is required",
+	} {
+		tt.Contains(err.Error(), path)
+	}
+	tt.NotContains(err.Error(), "handlers[0]")
+	tt.NotContains(err.Error(), "handlers[1].format")
+
+	_, err = config.Parse([]byte("handlers:\n  - type: human\n    colour: red\n"))
+	tt.ErrorContains(err, "field colour not found")
+}
+
+func Test__Config__FromEnv(t *testing.T) {
+	tt := assert.New(t)
+
+	t.Setenv(config.EnvLevel, "debug")
+	t.Setenv(config.EnvFormat, "json")
+	t.Setenv(config.EnvOutput, "stdout")
+	t.Setenv(config.EnvAddSource, "1")
+	c, err := config.FromEnv()
+	tt.NoError(err)
+	tt.EqualValues(&config.Config{
+		Level:    "debug",
+		Handlers: []config.HandlerConfig{{Type: "json", Output: "stdout", AddSource: true}},
+	}, c)
+
+	path := filepath.Join(t.TempDir(), "mlog.yaml")
+	t.Setenv(config.EnvFormat, "xml")
+	_, err = config.FromEnv()
+	tt.ErrorContains(err, config.EnvFormat+`: unknown value "xml"`)
+	tt.NotContains(err.Error(), "handlers[0]")
+
+	tt.NoError(os.WriteFile(path, []byte("level: info\nhandlers: [{type: text}]\n"), 0o600))
+	t.Setenv(config.EnvConfig, path)
+	c, err = config.FromEnv()
+	tt.NoError(err)
+	tt.EqualValues("debug", c.Level)
+	tt.EqualValues("text", c.Handlers[0].Type)
+
+	t.Setenv(config.EnvLevel, "loud")
+	_, err = config.FromEnv()
+	tt.ErrorContains(err, config.EnvLevel+": unknown value")
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Environment variables, used by [FromEnv].
+const (
+	// EnvConfig is the path of the configuration file.
+	EnvConfig = "MLOG_CONFIG"
+	// EnvLevel is the default level of handlers, it overrides the level of the configuration file.
+	EnvLevel = "MLOG_LEVEL"
+	// EnvFormat is the handler type, if the configuration file is not given. Default is "human".
+	EnvFormat = "MLOG_FORMAT"
+	// EnvOutput is the handler output, if the configuration file is not given. Default is "stderr".
+	EnvOutput = "MLOG_OUTPUT"
+	// EnvAddSource is a boolean, like "true" or "1", which enables the source code position,
+	// if the configuration file is not given.
+	EnvAddSource = "MLOG_ADD_SOURCE"
+)
+
+// FromEnv returns the configuration, described by environment variables: the file MLOG_CONFIG, if set,
+// or the single handler with MLOG_FORMAT type, MLOG_OUTPUT output and MLOG_ADD_SOURCE flag.
+// MLOG_LEVEL sets the default level in both cases.
+func FromEnv() (*Config, error) {
+	var c *Config
+	fromFile := false
+	if path := os.Getenv(EnvConfig); path != "" {
+		var err error
+		if c, err = Load(path); err != nil {
+			return nil, err
+		}
+		fromFile = true
+	} else {
+		hc := HandlerConfig{
+			Type:   os.Getenv(EnvFormat),
+			Output: os.Getenv(EnvOutput),
+		}
+		if hc.Type == "" {
+			hc.Type = TypeHuman
+		}
+		if s := os.Getenv(EnvAddSource); s != "" {
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, &FieldError{Path: EnvAddSource, Err: fmt.Errorf("%w %q", ErrUnknownValue, s)}
+			}
+			hc.AddSource = v
+		}
+		c = &Config{Handlers: []HandlerConfig{hc}}
+	}
+	if l := os.Getenv(EnvLevel); l != "" {
+		if _, err := parseLevel(l, 0); err != nil {
+			return nil, &FieldError{Path: EnvLevel, Err: err}
+		}
+		c.Level = l
+	}
+	if err := c.Validate(); err != nil {
+		if !fromFile {
+			renameField(err, "handlers[0].type", EnvFormat) // the handler type comes from MLOG_FORMAT
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// renameField replaces the path of field errors, returned by [Config.Validate].
+func renameField(err error, from, to string) {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok { //nolint:errorlint
+		errs = joined.Unwrap()
+	}
+	for _, e := range errs {
+		if fe, ok := e.(*FieldError); ok && fe.Path == from { //nolint:errorlint
+			fe.Path = to
+		}
+	}
+}
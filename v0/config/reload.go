@@ -0,0 +1,202 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+// DefaultReloadInterval is a default period of checking the configuration file for changes.
+const DefaultReloadInterval = 5 * time.Second
+
+type ReloaderOptions struct {
+	// Path is the configuration file. If empty, MLOG_CONFIG environment variable is used.
+	Path string
+	// Interval is the period of checking the file modification time and size.
+	// If zero, [DefaultReloadInterval] is used, negative value disables polling.
+	Interval time.Duration
+	// Signals cause reload of the configuration. If nil, SIGHUP is used.
+	Signals []os.Signal
+	// CloseTimeout limits time to flush the previous handler tree after reload.
+	// If zero, [mlog.DefaultCloseTimeout] is used.
+	CloseTimeout time.Duration
+
+	// OnReload, if not nil, is called after the new configuration is applied.
+	OnReload func(c *Config)
+	// OnError, if not nil, is called when the background reload fails; the previous configuration is kept.
+	OnError func(err error)
+}
+
+// Reloader is a [slog.Handler], built from the configuration file, which is rebuilt when the file
+// is changed or the signal is received. The new handler tree replaces the previous one atomically
+// (see [mlog.SwapHandler]), then the previous tree is flushed and closed, so no records are lost.
+// Network writers with the same network, address and spool directory are passed to the new tree.
+type Reloader struct {
+	*mlog.SwapHandler
+	opts ReloaderOptions
+
+	mu      sync.Mutex // serializes reloads
+	tree    *Tree
+	modTime time.Time
+	size    int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReloader loads the configuration, builds the handler tree and starts watching for changes.
+// Close must be called to stop watching and to close the handler tree.
+func NewReloader(opts *ReloaderOptions) (*Reloader, error) {
+	r := &Reloader{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.Path == "" {
+		r.opts.Path = os.Getenv(EnvConfig)
+	}
+	if r.opts.Path == "" {
+		return nil, &FieldError{Path: EnvConfig, Err: ErrRequired}
+	}
+	if r.opts.Interval == 0 {
+		r.opts.Interval = DefaultReloadInterval
+	}
+	if r.opts.Signals == nil {
+		r.opts.Signals = []os.Signal{syscall.SIGHUP}
+	}
+	if r.opts.CloseTimeout <= 0 {
+		r.opts.CloseTimeout = mlog.DefaultCloseTimeout
+	}
+
+	c, tree, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.tree = tree
+	r.SwapHandler = mlog.NewSwapHandler(tree)
+	if r.opts.OnReload != nil {
+		r.opts.OnReload(c)
+	}
+	go r.watch()
+	return r, nil
+}
+
+// load builds the handler tree from the configuration file and remembers the file version.
+func (r *Reloader) load() (*Config, *Tree, error) {
+	fi, err := os.Stat(r.opts.Path)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck
+	}
+	r.modTime, r.size = fi.ModTime(), fi.Size() // the broken file is not reloaded until the next change
+	c, err := Load(r.opts.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	tree, err := c.build(r.tree) // network writers are reused, so spool directories are never shared
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", r.opts.Path, err)
+	}
+	return c, tree, nil
+}
+
+// Reload rebuilds the handler tree from the configuration file. If the configuration is invalid,
+// the error is returned and the current handler tree is kept.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tree == nil {
+		return mlog.ErrClosed
+	}
+	c, tree, err := r.load()
+	if err != nil {
+		return err
+	}
+	prev := r.tree
+	r.tree = tree
+	r.Swap(tree)
+	if r.opts.OnReload != nil {
+		r.opts.OnReload(c)
+	}
+	prev.disown(tree)
+	return closeTree(prev, r.opts.CloseTimeout)
+}
+
+// changed reports whether modification time or size of the configuration file differs from the loaded one.
+func (r *Reloader) changed() bool {
+	fi, err := os.Stat(r.opts.Path)
+	if err != nil {
+		return false // the file may be replaced right now, it will be checked next time
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !fi.ModTime().Equal(r.modTime) || fi.Size() != r.size
+}
+
+func (r *Reloader) watch() {
+	defer close(r.done)
+	signals := make(chan os.Signal, 1)
+	if len(r.opts.Signals) != 0 {
+		signal.Notify(signals, r.opts.Signals...)
+		defer signal.Stop(signals)
+	}
+	var tick <-chan time.Time
+	if r.opts.Interval > 0 {
+		ticker := time.NewTicker(r.opts.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-signals:
+		case <-tick:
+			if !r.changed() {
+				continue
+			}
+		}
+		if err := r.Reload(); err != nil && !errors.Is(err, mlog.ErrClosed) && r.opts.OnError != nil {
+			r.opts.OnError(err)
+		}
+	}
+}
+
+// Flush sends records, buffered by the current handler tree.
+func (r *Reloader) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tree == nil {
+		return mlog.ErrClosed
+	}
+	return r.tree.Flush(ctx)
+}
+
+// Close stops watching for changes, flushes and closes the current handler tree.
+func (r *Reloader) Close() error {
+	r.mu.Lock()
+	tree := r.tree
+	r.tree = nil
+	r.mu.Unlock()
+	if tree == nil {
+		return mlog.ErrClosed
+	}
+	close(r.stop)
+	<-r.done
+	return closeTree(tree, r.opts.CloseTimeout)
+}
+
+// closeTree flushes buffered records during timeout and closes the tree.
+func closeTree(t *Tree, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return errors.Join(t.Flush(ctx), t.Close())
+}
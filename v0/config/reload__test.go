@@ -0,0 +1,111 @@
+package config_test
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"github.com/xenolog/mlog/v0/config"
+)
+
+func Test__Reloader__Poll(t *testing.T) {
+	tt := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mlog.yaml")
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+	tt.NoError(os.WriteFile(path, []byte("handlers: [{type: human, output: "+first+"}]\n"), 0o600))
+
+	reloaded := make(chan *config.Config, 1)
+	failed := make(chan error, 1)
+	r, err := config.NewReloader(&config.ReloaderOptions{
+		Path:     path,
+		Interval: 10 * time.Millisecond,
+		OnReload: func(c *config.Config) { reloaded <- c },
+		OnError:  func(err error) { failed <- err },
+	})
+	tt.NoError(err)
+	<-reloaded
+	logger := slog.New(r).With("n", 1)
+	logger.Info("before")
+
+	tt.NoError(os.WriteFile(path, []byte("handlers: [{type: json, output: "+second+"}]\n"), 0o600))
+	select {
+	case c := <-reloaded:
+		tt.EqualValues("json", c.Handlers[0].Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("configuration is not reloaded")
+	}
+	logger.Info("after")
+
+	tt.NoError(os.WriteFile(path, []byte("handlers: [{type: xml}]\n"), 0o600))
+	select {
+	case err := <-failed:
+		tt.ErrorIs(err, config.ErrUnknownValue)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload error is not reported")
+	}
+	logger.Info("kept")
+	tt.NoError(r.Close())
+
+	data, err := os.ReadFile(first)
+	tt.NoError(err)
+	tt.EqualValues(1, strings.Count(string(data), "\n"))
+	tt.Contains(string(data), `before  ATTRS={"n":1}`)
+	data, err = os.ReadFile(second)
+	tt.NoError(err)
+	tt.EqualValues(2, strings.Count(string(data), "\n"))
+	tt.Contains(string(data), `"msg":"after","n":1}`)
+	tt.Contains(string(data), `"msg":"kept","n":1}`)
+}
+
+func Test__Reloader__SharedSpool(t *testing.T) {
+	tt := assert.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tt.NoError(err)
+	defer ln.Close()
+	conns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mlog.yaml")
+	handler := "{addr: " + ln.Addr().String() + ", spool: " + filepath.Join(dir, "spool") + ", type: "
+	tt.NoError(os.WriteFile(path, []byte("handlers: ["+handler+"human}]\n"), 0o600))
+	r, err := config.NewReloader(&config.ReloaderOptions{Path: path, Interval: -1})
+	tt.NoError(err)
+	logger := slog.New(r)
+	logger.Info("before")
+
+	tt.NoError(os.WriteFile(path, []byte("handlers: ["+handler+"json}, "+handler+"text}]\n"), 0o600))
+	tt.NoError(r.Reload())
+	logger.Info("after")
+	tt.NoError(r.Close())
+
+	conn := <-conns // the writer and its connection are passed to the new tree
+	defer conn.Close()
+	sc := bufio.NewScanner(conn)
+	lines := []string{}
+	for len(lines) < 3 && sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	tt.Len(lines, 3)
+	tt.Contains(lines[0], "before")
+	tt.Contains(lines[1], `"msg":"after"`)
+	tt.Contains(lines[2], "msg=after")
+	tt.Empty(conns)
+}
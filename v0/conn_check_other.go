@@ -0,0 +1,11 @@
+//go:build !unix
+
+package mlog
+
+import "net"
+
+// isConnClosed reports whether the stream connection was closed by the peer.
+// It is not implemented on this platform, so the write error is the only way to detect it.
+func isConnClosed(_ net.Conn) bool {
+	return false
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package mlog
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// isConnClosed reports whether the stream connection was closed by the peer.
+// Log collectors never send data, so readable socket means EOF or error.
+func isConnClosed(conn net.Conn) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+	closed := false
+	err = rc.Read(func(fd uintptr) bool {
+		var buf [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch {
+		case n == 0 && err == nil:
+			closed = true
+		case err != nil && !errors.Is(err, syscall.EAGAIN) && !errors.Is(err, syscall.EWOULDBLOCK):
+			closed = true
+		}
+		return true
+	})
+	return closed || err != nil
+}
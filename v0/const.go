@@ -1,16 +1,158 @@
 package mlog
 
-import "log/slog"
+import (
+	"log/slog"
+	"time"
+)
 
 const (
 	TimeOutputFormatRFC3339 = "2006-01-02T15:04:05.000000Z07"
 	LogLineBuffSize         = 1024
 	AttrsJSONprefix         = "ATTRS="
+	StackKey                = "stack"
+	TraceIDKey              = "trace_id"
+	SpanIDKey               = "span_id"
+
+	// LoggerKey is the attribute key of the logger name, see [Named].
+	LoggerKey = "logger"
+	// DurationKey and ErrorKey are attribute keys of the elapsed time and the error, see [Logger.Timed].
+	DurationKey = "duration"
+	ErrorKey    = "error"
+	// RequestIDKey is the attribute key of the request ID, see [AccessLogMiddleware].
+	RequestIDKey = "request_id"
+
+	// DefaultLevelHeader is a default request header name, used by [LevelMiddleware].
+	DefaultLevelHeader = "X-Log-Level"
+	// DefaultRequestIDHeader is a default header name of the request ID, used by [AccessLogMiddleware].
+	DefaultRequestIDHeader = "X-Request-Id"
+	// DefaultAccessLogMessage is a default message of [AccessLogMiddleware] records.
+	DefaultAccessLogMessage = "request"
+
+	// DefaultSyslogSDID is a default structured data element ID, used by [SyslogHandler].
+	// 32473 is a private enterprise number, reserved for documentation (RFC 5612).
+	DefaultSyslogSDID = "mlog@32473"
+
+	// DefaultJournaldSocket is a default path of the journald native protocol socket.
+	DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+	// DefaultGELFChunkSize is a default maximum size of GELF UDP datagram, it fits into the Ethernet MTU.
+	DefaultGELFChunkSize = 1420
+
+	// DefaultDialTimeout is a default timeout to connect to the network log collectors.
+	DefaultDialTimeout = 5 * time.Second
+
+	// DefaultMinBackoff and DefaultMaxBackoff limit a delay between reconnect attempts of [NetWriter].
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultNetWriterBufferSize is a default size (in bytes) of the [NetWriter] memory buffer.
+	DefaultNetWriterBufferSize = 1024 * 1024
+	// DefaultSpoolSegmentSize is a default size limit (in bytes) of one [NetWriter] spool segment file.
+	DefaultSpoolSegmentSize = 16 * 1024 * 1024
+	// DefaultCloseTimeout is a default time to send buffered messages on Close.
+	DefaultCloseTimeout = 5 * time.Second
+
+	// DefaultMaxBatchSize, DefaultFlushInterval, DefaultQueueSize and DefaultMaxRetries
+	// are default values of [BatchOptions].
+	DefaultMaxBatchSize  = 512
+	DefaultFlushInterval = time.Second
+	DefaultQueueSize     = 8192
+	DefaultMaxRetries    = 3
+	// DefaultHTTPTimeout is a default timeout of one request to the HTTP-based log collectors.
+	DefaultHTTPTimeout = 10 * time.Second
+
+	// DefaultOTLPEndpoint is a default OTLP/HTTP collector address.
+	DefaultOTLPEndpoint = "http://localhost:4318"
+
+	// DefaultLokiEndpoint is a default Loki address.
+	DefaultLokiEndpoint = "http://localhost:3100"
+	// DefaultMaxLabelValues is a default limit of distinct values of the label, taken from attributes by [LokiHandler].
+	DefaultMaxLabelValues = 100
+
+	// DefaultElasticEndpoint, DefaultElasticIndex and DefaultElasticIndexDateFormat are defaults of [ElasticHandlerOptions].
+	DefaultElasticEndpoint        = "http://localhost:9200"
+	DefaultElasticIndex           = "mlog"
+	DefaultElasticIndexDateFormat = "2006.01.02"
+	// ElasticAttrsKey is a name of the document field, which contains attributes of the record.
+	ElasticAttrsKey = "attrs"
+
+	// DefaultMetricNamespace is a default CloudWatch namespace of metrics, written by [CloudHandler],
+	// and a default prefix of metric names of [MetricsHandler].
+	DefaultMetricNamespace = "mlog"
+
+	// DefaultWebhookThrottleInterval, DefaultWebhookGroupInterval, DefaultBreakerThreshold and DefaultBreakerCooldown
+	// are defaults of [WebhookHandlerOptions].
+	DefaultWebhookThrottleInterval = time.Minute
+	DefaultWebhookGroupInterval    = 5 * time.Second
+	DefaultBreakerThreshold        = 5
+	DefaultBreakerCooldown         = time.Minute
+
+	// DefaultSaveInterval is a default period of saving [ErrorTracker] groups to the file.
+	DefaultSaveInterval = 10 * time.Second
+	// DefaultMaxErrorGroups is a default limit of [ErrorTracker] groups.
+	DefaultMaxErrorGroups = 1000
+
+	// DefaultTailSize is a default number of records, kept by [TailHandler].
+	DefaultTailSize = 1000
+	// DefaultTailKeepAlive is a default period of keep-alive comments in the [TailHandler] event stream.
+	DefaultTailKeepAlive = 15 * time.Second
+
+	// DefaultMaxAttrDepth is a default nesting limit of groups, maps and slices in the attribute value.
+	DefaultMaxAttrDepth = 16
+	// DefaultMaxAttrSize is a default limit (in bytes) of the string or JSON representation of a single attribute value.
+	DefaultMaxAttrSize = 64 * 1024
+)
+
+const (
+	panicValueFormat     = "!PANIC: %v"
+	errorValueFormat     = "!ERROR: %v"
+	maxDepthValue        = "!MAXDEPTH"
+	truncatedValueSuffix = "...!TRUNCATED"
+	maxLogValuerCalls    = 100 // the same limit as slog.Value.Resolve() has
+	maxStackDepth        = 64
+	stackIndent          = "    "
+	syslogTimeFormat     = "2006-01-02T15:04:05.000000Z07:00"
+	maxSDNameLength      = 32
+	maxJournalFieldName  = 64
+	journalFieldPrefix   = "X_"
+	gelfChunkHeaderSize  = 12
+	maxGELFChunks        = 128
+
+	spoolSegmentExt       = ".seg"
+	spoolRecordHeaderSize = 4
+	spoolFirstSeq         = 1 << 40 // leaves room for segments, prepended before the first one
+	spoolDirMode          = 0o750
+	spoolFileMode         = 0o640
+	maxResponseSize       = 1024 * 1024
+	maxErrorBodySize      = 512
+	otlpLogsPath          = "/v1/logs"
+	otlpScopeName         = "github.com/xenolog/mlog"
+	otlpSeverityInfo      = 9
+	otlpSeverityMax       = 24
+	snappyHashBits        = 14
+	snappyMaxOffset       = 1<<16 - 1
+	snappyMaxCopy         = 64
+	lokiPushPath          = "/loki/api/v1/push"
+	lokiLevelLabel        = "level"
+	lokiOverflowValue     = "_overflow_"
+	elasticECSVersion     = "8.11.0"
+	gcpSourceLocationKey  = "logging.googleapis.com/sourceLocation"
+	gcpTraceKey           = "logging.googleapis.com/trace"
+	gcpSpanIDKey          = "logging.googleapis.com/spanId"
+	gcpTraceSampledKey    = "logging.googleapis.com/trace_sampled"
+	maxThrottleEntries    = 1024
+	maxWebhookLines       = 10
+	tailSubscriberBuffer  = 256
+	fingerprintLength     = 16
+	requestIDSize         = 8
 )
 
 var level2Letter = map[slog.Level]string{ //nolint:gochecknoglobals
+	LevelTrace:      " T ",
 	slog.LevelDebug: " D ",
 	slog.LevelInfo:  " I ",
+	LevelNotice:     " N ",
 	slog.LevelWarn:  " W ",
 	slog.LevelError: " E ",
+	LevelCritical:   " C ",
+	LevelFatal:      " F ",
 }
@@ -0,0 +1,165 @@
+package mlog
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+type ctxKey int
+
+const (
+	ctxKeyAttrs ctxKey = iota
+	ctxKeyTrace
+	ctxKeyLevel
+	ctxKeyLogger
+)
+
+// WithAttrs returns a copy of ctx, which carries given attributes in addition to attributes,
+// already stored in ctx. Arguments are converted to attributes as [slog.Logger.Log] does.
+// Stored attributes are added to each record, logged with this context through [ContextHandler].
+func WithAttrs(ctx context.Context, args ...any) context.Context {
+	r := slog.Record{}
+	r.Add(args...)
+	aa := AttrsFromContext(ctx)
+	aa = aa[:len(aa):len(aa)] // force copy on append, because parent ctx owns the slice
+	r.Attrs(func(a slog.Attr) bool {
+		aa = append(aa, a)
+		return true
+	})
+	return context.WithValue(ctx, ctxKeyAttrs, aa)
+}
+
+// AttrsFromContext returns attributes, stored in ctx by [WithAttrs].
+func AttrsFromContext(ctx context.Context) []slog.Attr {
+	aa, _ := ctx.Value(ctxKeyAttrs).([]slog.Attr)
+	return aa
+}
+
+// WithLevel returns a copy of ctx, which lowers the minimum level of mlog handlers
+// for records, logged with this context. It allows to get Debug records for one request
+// without lowering the level globally. The override can't raise the level, so records, enabled
+// by the handler's own level, are never hidden. [HumanReadableHandler], [LevelHandler], [LevelRouter]
+// and loggers of [Loggers] (handlers, returned by [Registry.Register]) respect the override;
+// wrap other handlers by [LevelHandler] to opt them in. [MultipleHandler] lets each of its handlers decide.
+func WithLevel(ctx context.Context, level slog.Leveler) context.Context {
+	return context.WithValue(ctx, ctxKeyLevel, level)
+}
+
+// overrideEnabled reports whether the level override, stored in ctx by [WithLevel], enables the level.
+func overrideEnabled(ctx context.Context, level slog.Level) bool {
+	l, ok := LevelFromContext(ctx)
+	return ok && level >= l
+}
+
+// LevelFromContext returns the level override, stored in ctx by [WithLevel].
+func LevelFromContext(ctx context.Context) (slog.Level, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	l, ok := ctx.Value(ctxKeyLevel).(slog.Leveler)
+	if !ok || l == nil {
+		return 0, false
+	}
+	return l.Level(), true
+}
+
+// WithLogger returns a copy of ctx, which carries the logger, for example the request-scoped logger
+// of [AccessLogMiddleware].
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKeyLogger, logger)
+}
+
+// LoggerFromContext returns the logger, stored in ctx by [WithLogger], or [slog.Default] if there is no one.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKeyLogger).(*slog.Logger); ok && l != nil {
+			return l
+		}
+	}
+	return slog.Default()
+}
+
+// -----------------------------------------------------------------------------
+
+// TraceContext identifies the trace and the span as described by W3C Trace Context specification.
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// IsValid reports whether both TraceID and SpanID are non-zero.
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceID != [16]byte{} && tc.SpanID != [8]byte{}
+}
+
+// Sampled reports whether the "sampled" flag is set.
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&1 != 0
+}
+
+// TraceIDString returns TraceID as lowercase hex string.
+func (tc TraceContext) TraceIDString() string {
+	return hex.EncodeToString(tc.TraceID[:])
+}
+
+// SpanIDString returns SpanID as lowercase hex string.
+func (tc TraceContext) SpanIDString() string {
+	return hex.EncodeToString(tc.SpanID[:])
+}
+
+// String returns the trace context in the "traceparent" header format.
+func (tc TraceContext) String() string {
+	return fmt.Sprintf("00-%s-%s-%02x", tc.TraceIDString(), tc.SpanIDString(), tc.Flags)
+}
+
+// ParseTraceParent parses the value of W3C "traceparent" header, like
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceParent(s string) (TraceContext, error) {
+	rv := TraceContext{}
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return rv, fmt.Errorf("%w: %q", ErrInvalidTraceParent, s)
+	}
+	var flags [1]byte
+	if len(parts[1]) != 2*len(rv.TraceID) || len(parts[2]) != 2*len(rv.SpanID) || len(parts[3]) != 2 {
+		return rv, fmt.Errorf("%w: %q", ErrInvalidTraceParent, s)
+	}
+	if _, err := hex.Decode(rv.TraceID[:], []byte(parts[1])); err != nil {
+		return rv, fmt.Errorf("%w: %w", ErrInvalidTraceParent, err)
+	}
+	if _, err := hex.Decode(rv.SpanID[:], []byte(parts[2])); err != nil {
+		return rv, fmt.Errorf("%w: %w", ErrInvalidTraceParent, err)
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return rv, fmt.Errorf("%w: %w", ErrInvalidTraceParent, err)
+	}
+	rv.Flags = flags[0]
+	if !rv.IsValid() {
+		return rv, fmt.Errorf("%w: %q", ErrInvalidTraceParent, s)
+	}
+	return rv, nil
+}
+
+// WithTrace returns a copy of ctx, which carries given trace context.
+func WithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, ctxKeyTrace, tc)
+}
+
+// WithTraceParent returns a copy of ctx, which carries trace context, parsed from "traceparent" header value.
+func WithTraceParent(ctx context.Context, traceParent string) (context.Context, error) {
+	tc, err := ParseTraceParent(traceParent)
+	if err != nil {
+		return ctx, err
+	}
+	return WithTrace(ctx, tc), nil
+}
+
+// TraceFromContext returns the trace context, stored in ctx by [WithTrace] or [WithTraceParent].
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(ctxKeyTrace).(TraceContext)
+	return tc, ok && tc.IsValid()
+}
@@ -0,0 +1,124 @@
+package mlog
+
+import (
+	"context"
+	"log/slog"
+	"runtime/pprof"
+)
+
+// ContextExtractor returns attributes, derived from the context.
+type ContextExtractor func(ctx context.Context) []slog.Attr
+
+// ContextHandlerOptions are options for a [ContextHandler].
+type ContextHandlerOptions struct {
+	// Extractors are called for each record to get attributes from the context.
+	// If nil, [ContextAttrs] and [TraceAttrs] are used.
+	Extractors []ContextExtractor
+}
+
+// ContextHandler is a [slog.Handler] middleware that adds attributes, extracted from
+// the context.Context, passed to Handle, to the record and passes it to the next handler.
+// Extracted attributes are added as record attributes, so they are placed into the current group, if any.
+type ContextHandler struct {
+	next       slog.Handler
+	extractors []ContextExtractor
+}
+
+// NewContextHandler creates a ContextHandler that passes records to the next handler,
+// using the given options. If opts is nil, the default options are used.
+func NewContextHandler(next slog.Handler, opts *ContextHandlerOptions) *ContextHandler {
+	h := &ContextHandler{
+		next:       next,
+		extractors: []ContextExtractor{ContextAttrs, TraceAttrs},
+	}
+	if opts != nil && opts.Extractors != nil {
+		h.extractors = opts.Extractors
+	}
+	return h
+}
+
+// Enabled reports whether the next handler handles records at the given level.
+// Implements [slog.Handler] interface.
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle adds attributes, extracted from ctx, to the record and passes it to the next handler.
+// Implements [slog.Handler] interface.
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	cloned := false
+	for _, extract := range h.extractors {
+		aa := extract(ctx)
+		if len(aa) == 0 {
+			continue
+		}
+		if !cloned {
+			r = r.Clone() // record can share attrs storage with other copies
+			cloned = true
+		}
+		r.AddAttrs(aa...)
+	}
+	return h.next.Handle(ctx, r) //nolint:wrapcheck
+}
+
+// WithAttrs returns a new ContextHandler whose next handler has given attributes.
+// Implements [slog.Handler] interface.
+func (h *ContextHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &ContextHandler{
+		next:       h.next.WithAttrs(aa),
+		extractors: h.extractors,
+	}
+}
+
+// WithGroup returns a new ContextHandler whose next handler has the given group.
+// Implements [slog.Handler] interface.
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &ContextHandler{
+		next:       h.next.WithGroup(name),
+		extractors: h.extractors,
+	}
+}
+
+// Flush flushes the next handler, if it implements [Flusher].
+func (h *ContextHandler) Flush(ctx context.Context) error {
+	return flushNext(ctx, h.next)
+}
+
+// Close closes the next handler, if it implements [Closer].
+func (h *ContextHandler) Close() error {
+	return closeNext(h.next)
+}
+
+// -----------------------------------------------------------------------------
+
+// ContextAttrs is a [ContextExtractor] which returns attributes, stored in ctx by [WithAttrs].
+func ContextAttrs(ctx context.Context) []slog.Attr {
+	return AttrsFromContext(ctx)
+}
+
+// TraceAttrs is a [ContextExtractor] which returns "trace_id", "span_id" attributes
+// if ctx carries trace context, see [WithTrace].
+func TraceAttrs(ctx context.Context) []slog.Attr {
+	tc, ok := TraceFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return []slog.Attr{
+		slog.String(TraceIDKey, tc.TraceIDString()),
+		slog.String(SpanIDKey, tc.SpanIDString()),
+	}
+}
+
+// PprofLabels is a [ContextExtractor] which returns profiler labels, stored in ctx
+// by [pprof.WithLabels] or [pprof.Do], as attributes.
+func PprofLabels(ctx context.Context) []slog.Attr {
+	var rv []slog.Attr
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		rv = append(rv, slog.String(key, value))
+		return true
+	})
+	return rv
+}
@@ -0,0 +1,88 @@
+package mlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"runtime/pprof"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func Test__ContextHandler__Attrs(t *testing.T) {
+	tt := assert.New(t)
+
+	nativeWriter := &bytes.Buffer{}
+	svWriter := &bytes.Buffer{}
+	nativeHandler := slog.NewJSONHandler(nativeWriter, nil)
+	svHandler := mlog.NewHumanReadableHandler(svWriter, nil)
+	logger := slog.New(mlog.NewContextHandler(mlog.NewMultipleHandler(nil, nativeHandler, svHandler), nil))
+
+	ctx := mlog.WithAttrs(context.Background(), "request_id", "r-1")
+	ctx = mlog.WithAttrs(ctx, slog.Int("user", 42))
+	ctx, err := mlog.WithTraceParent(ctx, traceParent)
+	tt.NoError(err)
+
+	logger.InfoContext(ctx, "with context", "own", true)
+	logger.Info("without context")
+
+	lines := bytes.Split(bytes.TrimSpace(nativeWriter.Bytes()), []byte("\n"))
+	tt.Len(lines, 2)
+	nativeData := map[string]any{}
+	tt.NoError(json.Unmarshal(lines[0], &nativeData))
+	tt.EqualValues("r-1", nativeData["request_id"])
+	tt.EqualValues(42, nativeData["user"])
+	tt.EqualValues(true, nativeData["own"])
+	tt.EqualValues("4bf92f3577b34da6a3ce929d0e0e4736", nativeData[mlog.TraceIDKey])
+	tt.EqualValues("00f067aa0ba902b7", nativeData[mlog.SpanIDKey])
+
+	nativeData = map[string]any{}
+	tt.NoError(json.Unmarshal(lines[1], &nativeData))
+	tt.NotContains(nativeData, "request_id")
+
+	svLines := bytes.Split(svWriter.Bytes(), []byte("\n"))
+	svData := hrAttrs(t, bytes.NewBuffer(svLines[0]))
+	tt.EqualValues("r-1", svData["request_id"])
+	tt.EqualValues("4bf92f3577b34da6a3ce929d0e0e4736", svData[mlog.TraceIDKey])
+}
+
+func Test__ContextHandler__PprofLabels(t *testing.T) {
+	tt := assert.New(t)
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewContextHandler(mlog.NewHumanReadableHandler(svWriter, nil), &mlog.ContextHandlerOptions{
+		Extractors: []mlog.ContextExtractor{mlog.PprofLabels},
+	}))
+
+	pprof.Do(context.Background(), pprof.Labels("worker", "w1"), func(ctx context.Context) {
+		logger.InfoContext(ctx, "labeled")
+	})
+
+	svData := hrAttrs(t, svWriter)
+	tt.EqualValues("w1", svData["worker"])
+}
+
+func Test__ContextHandler__ParseTraceParent(t *testing.T) {
+	tt := assert.New(t)
+
+	tc, err := mlog.ParseTraceParent(traceParent)
+	tt.NoError(err)
+	tt.True(tc.Sampled())
+	tt.EqualValues(traceParent, tc.String())
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bZ-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		_, err := mlog.ParseTraceParent(bad)
+		tt.ErrorIs(err, mlog.ErrInvalidTraceParent, bad)
+	}
+}
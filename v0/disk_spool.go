@@ -0,0 +1,218 @@
+package mlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diskSpool is a write-ahead queue of messages, stored in the segment files.
+// Each message is stored as 4-byte big-endian length followed by the message data.
+// Segments are named by the sequence number and are read in this order,
+// fully read segments are removed. It is not safe for concurrent use.
+type diskSpool struct {
+	dir        string
+	maxSegment int64
+	maxTotal   int64
+	total      int64   // size of the unread data
+	segments   []int64 // sequence numbers of the existing segments in the read order
+
+	wf   *os.File // segment to append, always the last one
+	wseq int64
+
+	rf      *os.File // segment to read, always the first one
+	rd      *bufio.Reader
+	rseq    int64
+	pending []byte // peeked, but not advanced message
+}
+
+func openDiskSpool(dir string, maxSegment, maxTotal int64) (*diskSpool, error) {
+	if err := os.MkdirAll(dir, spoolDirMode); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	s := &diskSpool{
+		dir:        dir,
+		maxSegment: maxSegment,
+		maxTotal:   maxTotal,
+	}
+	for _, e := range entries {
+		seq, ok := parseSegmentName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		if info.Size() == 0 {
+			os.Remove(filepath.Join(dir, e.Name()))
+			continue
+		}
+		s.segments = append(s.segments, seq)
+		s.total += info.Size()
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+	return s, nil
+}
+
+func parseSegmentName(name string) (int64, bool) {
+	base, ok := strings.CutSuffix(name, spoolSegmentExt)
+	if !ok {
+		return 0, false
+	}
+	seq, err := strconv.ParseInt(base, 10, 64)
+	return seq, err == nil
+}
+
+func (s *diskSpool) segmentPath(seq int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolSegmentExt))
+}
+
+func (s *diskSpool) empty() bool {
+	return len(s.segments) == 0
+}
+
+func (s *diskSpool) append(msg []byte) error {
+	size := int64(len(msg) + spoolRecordHeaderSize)
+	if s.maxTotal > 0 && s.total+size > s.maxTotal {
+		return ErrBufferFull
+	}
+	if s.wf != nil {
+		if info, err := s.wf.Stat(); err != nil || info.Size()+size > s.maxSegment {
+			s.wf.Close()
+			s.wf = nil
+		}
+	}
+	if s.wf == nil {
+		seq := int64(spoolFirstSeq)
+		if len(s.segments) != 0 {
+			seq = s.segments[len(s.segments)-1] + 1
+		}
+		f, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, spoolFileMode)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		s.wf, s.wseq = f, seq
+		s.segments = append(s.segments, seq)
+	}
+	if _, err := s.wf.Write(encodeSpoolRecord(msg)); err != nil {
+		return err //nolint:wrapcheck
+	}
+	s.total += size
+	return nil
+}
+
+// prepend stores messages into the new segment, which will be read before existing ones.
+func (s *diskSpool) prepend(msgs [][]byte) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	seq := int64(spoolFirstSeq)
+	if len(s.segments) != 0 {
+		seq = s.segments[0] - 1
+		if s.rf != nil { // the first segment is partially read, put messages before its rest
+			s.rf.Close()
+			s.rf, s.rd, s.pending = nil, nil, nil
+		}
+	}
+	var buf []byte
+	for _, msg := range msgs {
+		buf = append(buf, encodeSpoolRecord(msg)...)
+	}
+	if err := os.WriteFile(s.segmentPath(seq), buf, spoolFileMode); err != nil {
+		return err //nolint:wrapcheck
+	}
+	s.segments = append([]int64{seq}, s.segments...)
+	s.total += int64(len(buf))
+	return nil
+}
+
+// peek returns the next message without removing it, or nil if the spool is empty
+// or the first segment is over. Broken segment is removed and error is returned.
+func (s *diskSpool) peek() ([]byte, error) {
+	if s.pending != nil {
+		return s.pending, nil
+	}
+	if s.empty() {
+		return nil, nil
+	}
+	if s.rf == nil {
+		f, err := os.Open(s.segmentPath(s.segments[0]))
+		if err != nil {
+			s.removeFirst()
+			return nil, err //nolint:wrapcheck
+		}
+		s.rf, s.rd, s.rseq = f, bufio.NewReader(f), s.segments[0]
+	}
+	var header [spoolRecordHeaderSize]byte
+	_, err := io.ReadFull(s.rd, header[:])
+	if err == nil {
+		msg := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err = io.ReadFull(s.rd, msg); err == nil {
+			s.pending = msg
+			return msg, nil
+		}
+	}
+	// the segment is over or broken, remove it
+	s.removeFirst()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	return nil, err //nolint:wrapcheck
+}
+
+// advance removes the peeked message.
+func (s *diskSpool) advance() {
+	if s.pending == nil {
+		return
+	}
+	s.total -= int64(len(s.pending) + spoolRecordHeaderSize)
+	s.pending = nil
+}
+
+func (s *diskSpool) removeFirst() {
+	seq := s.segments[0]
+	if s.rf != nil {
+		s.rf.Close()
+		s.rf, s.rd, s.pending = nil, nil, nil
+	}
+	if s.wf != nil && s.wseq == seq {
+		s.wf.Close()
+		s.wf = nil
+	}
+	os.Remove(s.segmentPath(seq))
+	s.segments = s.segments[1:]
+	if len(s.segments) == 0 {
+		s.total = 0 // drops the rest of the broken segment
+	}
+}
+
+func (s *diskSpool) close() error {
+	var errs []error
+	if s.rf != nil {
+		errs = append(errs, s.rf.Close())
+		s.rf, s.rd, s.pending = nil, nil, nil
+	}
+	if s.wf != nil {
+		errs = append(errs, s.wf.Close())
+		s.wf = nil
+	}
+	return errors.Join(errs...)
+}
+
+func encodeSpoolRecord(msg []byte) []byte {
+	rv := make([]byte, spoolRecordHeaderSize, spoolRecordHeaderSize+len(msg))
+	binary.BigEndian.PutUint32(rv, uint32(len(msg)))
+	return append(rv, msg...)
+}
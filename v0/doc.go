@@ -11,6 +11,14 @@ which provides additional functionality to the stdlib [log/slog].
 [MultipleHandler] allows to write one log event to multiple destinations.
 See `examples/multiple_destinations.go` to usage.
 
+# ContextHandler
+
+[ContextHandler] adds attributes, derived from the context.Context, to each record.
+Attributes can be stored in the context by [WithAttrs], trace and span IDs by [WithTrace]:
+
+	ctx = mlog.WithAttrs(ctx, "request_id", id)
+	logger.InfoContext(ctx, "hello") // record will have request_id attribute
+
 # HumanReadableHandler
 
 [HumanReadableHandler] is a alternative structured log representation where
@@ -0,0 +1,292 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ElasticHandlerOptions struct {
+	// Endpoint is the Elasticsearch URL, like "http://localhost:9200". If empty, [DefaultElasticEndpoint] is used.
+	Endpoint string
+	// Index is the index name prefix, the date of the record is appended to it, like "mlog-2024.03.15".
+	// If empty, [DefaultElasticIndex] is used.
+	Index string
+	// IndexDateFormat is the [time.Time.Format] layout of the index date, which is taken in UTC.
+	// If empty, [DefaultElasticIndexDateFormat] is used, i.e. indexes are daily.
+	IndexDateFormat string
+
+	// APIKey is sent as "Authorization: ApiKey ..." header.
+	APIKey string
+	// Username and Password are used for the basic authentication.
+	Username string
+	Password string
+	// Headers are added to each request.
+	Headers map[string]string
+	// Client is used to send requests. If nil, the client with [DefaultHTTPTimeout] is used.
+	Client *http.Client
+
+	// ServiceName is the "service.name" field. If empty, the field is not sent.
+	ServiceName string
+
+	// AddSource causes the handler to add "log.origin.*" fields with the source code position of the log statement.
+	AddSource bool
+
+	// StackTraceLevel enables "error.stack_trace" field for records with level at or above given,
+	// see [HumanReadableHandlerOptions.StackTraceLevel]. If nil, stack traces are not sent.
+	StackTraceLevel slog.Leveler
+
+	// Batch configures batching, backpressure and retries of the requests.
+	Batch BatchOptions
+
+	// MaxAttrDepth and MaxAttrSize limit attribute values, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to log.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelInfo].
+	Level slog.Leveler
+}
+
+// ElasticHandler is a [slog.Handler] that indexes Records into Elasticsearch, using the _bulk API.
+// Records are mapped to Elastic Common Schema (ECS) fields: "@timestamp", "message", "log.level",
+// "log.origin.*", "error.*", "trace.id", "span.id"; attributes are stored in the [ElasticAttrsKey] object.
+// Records are queued and indexed in batches by the background goroutine. Documents, rejected
+// by Elasticsearch because of overload, are retried, other rejected documents are dropped.
+type ElasticHandler struct {
+	opts    ElasticHandlerOptions
+	state   handlerState
+	batcher *batcher[elasticDoc]
+}
+
+type elasticDoc struct {
+	index string
+	doc   []byte
+}
+
+// NewElasticHandler creates an ElasticHandler, using the given options, and starts the background sending goroutine.
+func NewElasticHandler(opts *ElasticHandlerOptions) *ElasticHandler {
+	h := &ElasticHandler{}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	if h.opts.Endpoint == "" {
+		h.opts.Endpoint = DefaultElasticEndpoint
+	}
+	if h.opts.Index == "" {
+		h.opts.Index = DefaultElasticIndex
+	}
+	if h.opts.IndexDateFormat == "" {
+		h.opts.IndexDateFormat = DefaultElasticIndexDateFormat
+	}
+	if h.opts.Client == nil {
+		h.opts.Client = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+	h.state = newHandlerState(newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize))
+
+	headers := maps.Clone(h.opts.Headers)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	switch {
+	case h.opts.APIKey != "":
+		headers["Authorization"] = "ApiKey " + h.opts.APIKey
+	case h.opts.Username != "":
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(h.opts.Username+":"+h.opts.Password))
+	}
+	sender := httpSender{
+		client:       h.opts.Client,
+		url:          strings.TrimSuffix(h.opts.Endpoint, "/") + "/_bulk",
+		headers:      headers,
+		fullResponse: true, // results of all items are needed
+	}
+	h.batcher = newBatcher(h.opts.Batch, func(ctx context.Context, batch []elasticDoc) error {
+		var body bytes.Buffer
+		for i := range batch {
+			fmt.Fprintf(&body, `{"create":{"_index":%q}}`+"\n", batch[i].index)
+			body.Write(batch[i].doc)
+			body.WriteByte('\n')
+		}
+		resp, err := sender.post(ctx, "application/x-ndjson", body.Bytes())
+		if err != nil {
+			return err
+		}
+		return elasticBulkErrors(resp, batch)
+	})
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *ElasticHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle maps the Record to ECS document and queues it.
+// [ErrBufferFull] is returned if the queue is full.
+// Implements [slog.Handler] interface.
+func (h *ElasticHandler) Handle(ctx context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	doc, err := json.Marshal(h.document(ctx, &r, t))
+	if err != nil {
+		return errors.Join(Error, err)
+	}
+	if err := h.batcher.add(elasticDoc{index: h.opts.Index + "-" + t.UTC().Format(h.opts.IndexDateFormat), doc: doc}); err != nil {
+		return errors.Join(Error, err)
+	}
+	return nil
+}
+
+// document returns ECS fields of the record.
+func (h *ElasticHandler) document(ctx context.Context, r *slog.Record, t time.Time) map[string]any {
+	rv := map[string]any{
+		"@timestamp":    t.UTC().Format(time.RFC3339Nano),
+		"message":       r.Message,
+		"log.level":     strings.ToLower(LevelString(r.Level)),
+		"ecs.version":   elasticECSVersion,
+		"host.hostname": hostname(),
+	}
+	if h.opts.ServiceName != "" {
+		rv["service.name"] = h.opts.ServiceName
+	}
+	if h.opts.AddSource && r.PC != 0 {
+		source := DecodeSource(r.PC)
+		rv["log.origin.file.name"] = sourceLocation(source, SourceRelativePath)
+		rv["log.origin.file.line"] = source.Line
+		rv["log.origin.function"] = source.Function
+	}
+	if err := recordError(r); err != nil {
+		rv["error.message"] = h.state.enc.truncate(err.Error())
+		rv["error.type"] = fmt.Sprintf("%T", err)
+	}
+	if h.opts.StackTraceLevel != nil && r.Level >= h.opts.StackTraceLevel.Level() {
+		if stack := recordStack(r); len(stack) != 0 {
+			rv["error.stack_trace"] = string(appendStack(nil, stack))
+		}
+	}
+	if tc, ok := TraceFromContext(ctx); ok {
+		rv["trace.id"] = tc.TraceIDString()
+		rv["span.id"] = tc.SpanIDString()
+	}
+	if attrs, _ := h.state.tree(r); len(attrs) != 0 {
+		rv[ElasticAttrsKey] = attrs
+	}
+	return rv
+}
+
+// WithAttrs returns a new ElasticHandler whose attributes consists of h's attributes followed by attrs.
+// Implements [slog.Handler] interface.
+func (h *ElasticHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &ElasticHandler{
+		opts:    h.opts,
+		state:   h.state.withAttrs(aa),
+		batcher: h.batcher,
+	}
+}
+
+// WithGroup returns a new ElasticHandler with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *ElasticHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &ElasticHandler{
+		opts:    h.opts,
+		state:   h.state.withGroup(name),
+		batcher: h.batcher,
+	}
+}
+
+// Flush sends all queued records and returns the error of the last failed request.
+func (h *ElasticHandler) Flush(ctx context.Context) error {
+	return h.batcher.flush(ctx)
+}
+
+// Close sends queued records during [BatchOptions.CloseTimeout] and stops the background goroutine.
+// The queue is shared by all handlers, derived by WithAttrs and WithGroup.
+func (h *ElasticHandler) Close() error {
+	return h.batcher.close()
+}
+
+// Dropped returns the number of records, which were dropped because of queue overflow,
+// failed requests or rejection by Elasticsearch.
+func (h *ElasticHandler) Dropped() uint64 {
+	return h.batcher.dropped.Load()
+}
+
+// -----------------------------------------------------------------------------
+
+// elasticBulkErrors checks results of the bulk request items. Items, rejected because of overload (429)
+// or server errors, are returned for retry. If the response reports errors, but can't be parsed,
+// all documents are counted as lost, because it is unknown which of them were rejected.
+func elasticBulkErrors(resp []byte, batch []elasticDoc) error {
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		if !bytes.Contains(resp, []byte(`"errors":true`)) {
+			return nil // the request is accepted, the response details are not important
+		}
+		return partialError[elasticDoc]{
+			err:  fmt.Errorf("%w: %d documents, invalid response: %w", ErrDocumentsRejected, len(batch), err),
+			lost: len(batch),
+		}
+	}
+	if !result.Errors {
+		return nil
+	}
+	var (
+		retry  []elasticDoc
+		failed int
+		first  string
+	)
+	for i, item := range result.Items {
+		for _, res := range item { // the only key is the action name
+			if res.Status < 300 || i >= len(batch) {
+				continue
+			}
+			failed++
+			if first == "" {
+				first = res.Error.Type + ": " + res.Error.Reason
+			}
+			if res.Status == http.StatusTooManyRequests || res.Status >= 500 {
+				retry = append(retry, batch[i])
+			}
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return partialError[elasticDoc]{
+		err:   fmt.Errorf("%w: %d of %d documents, %s", ErrDocumentsRejected, failed, len(batch), first),
+		lost:  failed - len(retry),
+		retry: retry,
+	}
+}
@@ -0,0 +1,163 @@
+package mlog_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+type bulkRequest struct {
+	actions []map[string]any
+	docs    []map[string]any
+}
+
+// newBulkServer returns a stand-in of Elasticsearch _bulk API, which replies with given
+// item statuses to the first request and accepts all items of next ones.
+func newBulkServer(t *testing.T, statuses ...int) (*httptest.Server, func() []bulkRequest) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		requests []bulkRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/_bulk", r.URL.Path)
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+		req := bulkRequest{}
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			action, doc := map[string]any{}, map[string]any{}
+			assert.NoError(t, json.Unmarshal(sc.Bytes(), &action))
+			assert.True(t, sc.Scan())
+			assert.NoError(t, json.Unmarshal(sc.Bytes(), &doc))
+			req.actions = append(req.actions, action)
+			req.docs = append(req.docs, doc)
+		}
+		mu.Lock()
+		first := len(requests) == 0
+		requests = append(requests, req)
+		mu.Unlock()
+
+		items := []any{}
+		hasErrors := false
+		for i := range req.docs {
+			status := http.StatusCreated
+			if first && i < len(statuses) {
+				status = statuses[i]
+			}
+			item := map[string]any{"status": status}
+			if status >= 300 {
+				hasErrors = true
+				item["error"] = map[string]any{"type": "some_exception", "reason": fmt.Sprint("status ", status)}
+			}
+			items = append(items, map[string]any{"create": item})
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"errors": hasErrors, "items": items}))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []bulkRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func Test__ElasticHandler__ECS(t *testing.T) {
+	tt := assert.New(t)
+
+	server, requests := newBulkServer(t)
+	h := mlog.NewElasticHandler(&mlog.ElasticHandlerOptions{
+		Endpoint:    server.URL,
+		Index:       "logs-app",
+		ServiceName: "svc",
+		AddSource:   true,
+	})
+	defer h.Close()
+	ctx, err := mlog.WithTraceParent(context.Background(), traceParent)
+	tt.NoError(err)
+
+	logger := slog.New(h).WithGroup("g")
+	logger.ErrorContext(ctx, "failed", "err", fmt.Errorf("wrapped: %w", io.EOF), "n", 1)
+	tt.NoError(h.Flush(flushCtx(t)))
+
+	reqs := requests()
+	tt.Len(reqs, 1)
+	tt.EqualValues("logs-app-"+time.Now().UTC().Format("2006.01.02"), JqMust(t, reqs[0].actions[0], `.create._index`))
+	doc := reqs[0].docs[0]
+	tt.EqualValues("failed", doc["message"])
+	tt.EqualValues("error", doc["log.level"])
+	tt.EqualValues("svc", doc["service.name"])
+	tt.EqualValues("v0/elastic_handler__test.go", doc["log.origin.file.name"])
+	tt.Contains(doc["log.origin.function"], "Test__ElasticHandler__ECS")
+	tt.EqualValues("wrapped: EOF", doc["error.message"])
+	tt.EqualValues("*fmt.wrapError", doc["error.type"])
+	tt.EqualValues("4bf92f3577b34da6a3ce929d0e0e4736", doc["trace.id"])
+	tt.EqualValues(map[string]any{"g": map[string]any{"err": "wrapped: EOF", "n": 1.0}}, doc[mlog.ElasticAttrsKey])
+	_, err = time.Parse(time.RFC3339Nano, doc["@timestamp"].(string))
+	tt.NoError(err)
+}
+
+func Test__ElasticHandler__ItemErrors(t *testing.T) {
+	tt := assert.New(t)
+
+	server, requests := newBulkServer(t, http.StatusCreated, http.StatusTooManyRequests, http.StatusBadRequest)
+	h := mlog.NewElasticHandler(&mlog.ElasticHandlerOptions{
+		Endpoint: server.URL,
+		Batch:    mlog.BatchOptions{MinBackoff: time.Millisecond},
+	})
+	defer h.Close()
+	logger := slog.New(h)
+	logger.Info("accepted")
+	logger.Info("overloaded")
+	logger.Info("bad mapping")
+	err := h.Flush(flushCtx(t))
+	tt.ErrorIs(err, mlog.ErrDocumentsRejected)
+	tt.True(strings.Contains(err.Error(), "2 of 3 documents"), err.Error())
+
+	reqs := requests()
+	tt.Len(reqs, 2)
+	tt.Len(reqs[0].docs, 3)
+	tt.Len(reqs[1].docs, 1) // only the overloaded document is retried
+	tt.EqualValues("overloaded", reqs[1].docs[0]["message"])
+	tt.EqualValues(1, h.Dropped())
+	tt.False(errors.Is(err, mlog.ErrUnexpectedStatus))
+}
+
+func Test__ElasticHandler__LargeResponse(t *testing.T) {
+	tt := assert.New(t)
+
+	responses := make(chan string, 2)
+	responses <- `{"errors":true,"items":[{"create":{"status":400,"error":{"type":"x","reason":"` + strings.Repeat("r", 2<<20) + `"}}}]}`
+	responses <- `{"took":1,"errors":true,"items":[{"create":`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)    //nolint:errcheck
+		io.WriteString(w, <-responses) //nolint:errcheck
+	}))
+	defer server.Close()
+	h := mlog.NewElasticHandler(&mlog.ElasticHandlerOptions{Endpoint: server.URL})
+	defer h.Close()
+	logger := slog.New(h)
+
+	logger.Info("rejected") // the response is larger than the limit of other responses
+	tt.ErrorIs(h.Flush(flushCtx(t)), mlog.ErrDocumentsRejected)
+	tt.EqualValues(1, h.Dropped())
+
+	logger.Info("unknown")
+	logger.Info("unknown")
+	err := h.Flush(flushCtx(t))
+	tt.ErrorIs(err, mlog.ErrDocumentsRejected)
+	tt.Contains(err.Error(), "invalid response")
+	tt.EqualValues(3, h.Dropped())
+}
@@ -0,0 +1,446 @@
+package mlog
+
+import (
+	"container/heap"
+	"context"
+	"crypto/sha1" //nolint:gosec // fingerprint is not a security feature
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type ErrorTrackerOptions struct {
+	// Path is the file to persist groups, so counts survive restarts. If empty, groups are kept in memory only.
+	Path string
+	// SaveInterval is the period of saving changed groups to the file. If zero, [DefaultSaveInterval] is used.
+	SaveInterval time.Duration
+	// MaxGroups limits the number of groups, least recently seen groups are evicted.
+	// If zero, [DefaultMaxErrorGroups] is used.
+	MaxGroups int
+
+	// MaxAttrDepth and MaxAttrSize limit attribute values of the sample records, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to track.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelError].
+	Level slog.Leveler
+}
+
+// ErrorGroup is a group of records with the same fingerprint.
+type ErrorGroup struct {
+	Fingerprint string    `json:"fingerprint"`
+	Message     string    `json:"message"`
+	ErrorType   string    `json:"error_type,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	Function    string    `json:"function,omitempty"`
+	Count       uint64    `json:"count"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	// Sample is the last record of the group.
+	Sample ErrorSample `json:"sample"`
+}
+
+// ErrorSample is a record, stored in the [ErrorGroup].
+type ErrorSample struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Error   string         `json:"error,omitempty"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	TraceID string         `json:"trace_id,omitempty"`
+}
+
+// ErrorTracker is a [slog.Handler] that groups error Records by fingerprint: the message text,
+// the type of the error attribute and the source code position of the log statement.
+// It keeps counts, first and last seen time and the sample record of each group, which are available
+// by [ErrorTracker.Groups] and as JSON over HTTP, because ErrorTracker is an [http.Handler] as well.
+// Groups are persisted in the file, if it is configured.
+type ErrorTracker struct {
+	opts  ErrorTrackerOptions
+	state handlerState
+	store *errorStore
+}
+
+type errorStore struct {
+	mu     sync.Mutex
+	path   string
+	max    int
+	groups map[string]*ErrorGroup
+	oldest groupHeap // groups by last seen time, the least recently seen group is evicted first
+	dirty  bool
+
+	closing chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewErrorTracker creates an ErrorTracker, using the given options, and loads groups from the file.
+// If the file is configured, it starts the background goroutine, which saves groups.
+func NewErrorTracker(opts *ErrorTrackerOptions) (*ErrorTracker, error) {
+	h := &ErrorTracker{}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelError
+	}
+	if h.opts.SaveInterval <= 0 {
+		h.opts.SaveInterval = DefaultSaveInterval
+	}
+	if h.opts.MaxGroups <= 0 {
+		h.opts.MaxGroups = DefaultMaxErrorGroups
+	}
+	h.state = newHandlerState(newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize))
+	h.store = &errorStore{
+		path:    h.opts.Path,
+		max:     h.opts.MaxGroups,
+		groups:  map[string]*ErrorGroup{},
+		oldest:  groupHeap{index: map[string]int{}},
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if h.opts.Path == "" {
+		close(h.store.done)
+		return h, nil
+	}
+	if err := h.store.load(); err != nil {
+		return nil, err
+	}
+	go h.store.run(h.opts.SaveInterval)
+	return h, nil
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *ErrorTracker) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle adds the Record to its group.
+// Implements [slog.Handler] interface.
+func (h *ErrorTracker) Handle(ctx context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	g := ErrorGroup{Message: r.Message}
+	sample := ErrorSample{
+		Time:    t,
+		Level:   LevelString(r.Level),
+		Message: r.Message,
+	}
+	if e := recordError(&r); e != nil {
+		g.ErrorType = fmt.Sprintf("%T", e)
+		sample.Error = h.state.enc.truncate(e.Error())
+	}
+	if r.PC != 0 {
+		source := DecodeSource(r.PC)
+		g.Source = FormatSource(source, SourceRelativePath)
+		g.Function = source.Function
+	}
+	if tc, ok := TraceFromContext(ctx); ok {
+		sample.TraceID = tc.TraceIDString()
+	}
+	sample.Attrs, _ = h.state.tree(&r)
+	g.Fingerprint = errorFingerprint(g.Message, g.ErrorType, g.Source)
+	h.store.add(&g, &sample)
+	return nil
+}
+
+// WithAttrs returns a new ErrorTracker whose attributes consists of h's attributes followed by attrs.
+// Implements [slog.Handler] interface.
+func (h *ErrorTracker) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &ErrorTracker{
+		opts:  h.opts,
+		state: h.state.withAttrs(aa),
+		store: h.store,
+	}
+}
+
+// WithGroup returns a new ErrorTracker with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *ErrorTracker) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &ErrorTracker{
+		opts:  h.opts,
+		state: h.state.withGroup(name),
+		store: h.store,
+	}
+}
+
+// Groups returns copies of all groups, most recently seen first.
+func (h *ErrorTracker) Groups() []ErrorGroup {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	return h.store.sorted()
+}
+
+// Group returns a copy of the group with the given fingerprint.
+func (h *ErrorTracker) Group(fingerprint string) (ErrorGroup, bool) {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	g, ok := h.store.groups[fingerprint]
+	if !ok {
+		return ErrorGroup{}, false
+	}
+	return *g, true
+}
+
+// Reset removes all groups.
+func (h *ErrorTracker) Reset() {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	h.store.groups = map[string]*ErrorGroup{}
+	h.store.oldest = groupHeap{index: map[string]int{}}
+	h.store.dirty = true
+}
+
+// ServeHTTP returns groups as JSON array, most recently seen first. The "fingerprint" query parameter
+// selects one group, the "limit" parameter limits the number of groups. DELETE method resets groups.
+// Implements [http.Handler] interface.
+func (h *ErrorTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var rv any
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if fp := r.URL.Query().Get("fingerprint"); fp != "" {
+			g, ok := h.Group(fp)
+			if !ok {
+				http.Error(w, "group not found", http.StatusNotFound)
+				return
+			}
+			rv = g
+			break
+		}
+		groups := h.Groups()
+		if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(groups) {
+			groups = groups[:limit]
+		}
+		rv = groups
+	case http.MethodDelete:
+		h.Reset()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "GET, HEAD, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, rv)
+}
+
+// Flush saves groups to the file.
+func (h *ErrorTracker) Flush(_ context.Context) error {
+	return h.store.save()
+}
+
+// Close stops the background goroutine and saves groups to the file. Later calls do nothing.
+func (h *ErrorTracker) Close() error {
+	var err error
+	h.store.once.Do(func() {
+		if h.opts.Path != "" {
+			close(h.store.closing)
+		}
+		<-h.store.done
+		err = h.store.save()
+	})
+	return err
+}
+
+// -----------------------------------------------------------------------------
+
+func errorFingerprint(parts ...string) string {
+	hash := sha1.New() //nolint:gosec
+	for _, p := range parts {
+		hash.Write([]byte(p))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:fingerprintLength]
+}
+
+func (s *errorStore) add(g *ErrorGroup, sample *ErrorSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	if existing, ok := s.groups[g.Fingerprint]; ok {
+		existing.Count++
+		existing.LastSeen = maxTime(existing.LastSeen, sample.Time)
+		existing.Sample = *sample
+		heap.Fix(&s.oldest, s.oldest.index[g.Fingerprint])
+		return
+	}
+	g.Count = 1
+	g.FirstSeen = sample.Time
+	g.LastSeen = sample.Time
+	g.Sample = *sample
+	s.groups[g.Fingerprint] = g
+	heap.Push(&s.oldest, g)
+	s.evict()
+}
+
+// evict removes the least recently seen groups above the limit. It must be called with locked mu.
+func (s *errorStore) evict() {
+	for len(s.groups) > s.max {
+		old := heap.Pop(&s.oldest).(*ErrorGroup) //nolint:forcetypeassert
+		delete(s.groups, old.Fingerprint)
+	}
+}
+
+// sorted returns copies of groups, most recently seen first. It must be called with locked mu.
+func (s *errorStore) sorted() []ErrorGroup {
+	rv := make([]ErrorGroup, 0, len(s.groups))
+	for _, g := range s.groups {
+		rv = append(rv, *g)
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		if rv[i].LastSeen.Equal(rv[j].LastSeen) {
+			return rv[i].Fingerprint < rv[j].Fingerprint
+		}
+		return rv[i].LastSeen.After(rv[j].LastSeen)
+	})
+	return rv
+}
+
+func (s *errorStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	groups := []*ErrorGroup{}
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	for _, g := range groups {
+		if _, ok := s.groups[g.Fingerprint]; !ok {
+			heap.Push(&s.oldest, g)
+		} else {
+			s.oldest.groups[s.oldest.index[g.Fingerprint]] = g
+		}
+		s.groups[g.Fingerprint] = g
+	}
+	heap.Init(&s.oldest)
+	s.evict() // the file may be saved with the greater limit
+	return nil
+}
+
+// save writes groups to the file, if they were changed.
+func (s *errorStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.sorted())
+	s.dirty = false
+	s.mu.Unlock()
+	if err == nil {
+		err = writeFileAtomic(s.path, data)
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// writeFileAtomic writes data to the temporary file and renames it, so the file is never partially written.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err //nolint:wrapcheck
+	}
+	if err := tmp.Close(); err != nil {
+		return err //nolint:wrapcheck
+	}
+	return os.Rename(tmp.Name(), path) //nolint:wrapcheck
+}
+
+func (s *errorStore) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.save() //nolint:errcheck // will be retried at the next tick or Close
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// groupHeap implements [heap.Interface], the least recently seen group is the first. Groups, seen at
+// the same time, are ordered like [errorStore.sorted] does in reverse.
+type groupHeap struct {
+	groups []*ErrorGroup
+	index  map[string]int // positions of groups by fingerprint
+}
+
+func (h *groupHeap) Len() int {
+	return len(h.groups)
+}
+
+func (h *groupHeap) Less(i, j int) bool {
+	a, b := h.groups[i], h.groups[j]
+	if a.LastSeen.Equal(b.LastSeen) {
+		return a.Fingerprint > b.Fingerprint
+	}
+	return a.LastSeen.Before(b.LastSeen)
+}
+
+func (h *groupHeap) Swap(i, j int) {
+	h.groups[i], h.groups[j] = h.groups[j], h.groups[i]
+	h.index[h.groups[i].Fingerprint] = i
+	h.index[h.groups[j].Fingerprint] = j
+}
+
+func (h *groupHeap) Push(x any) {
+	g := x.(*ErrorGroup) //nolint:forcetypeassert
+	h.index[g.Fingerprint] = len(h.groups)
+	h.groups = append(h.groups, g)
+}
+
+func (h *groupHeap) Pop() any {
+	n := len(h.groups) - 1
+	g := h.groups[n]
+	h.groups[n] = nil
+	h.groups = h.groups[:n]
+	delete(h.index, g.Fingerprint)
+	return g
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,119 @@
+package mlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__ErrorTracker__Groups(t *testing.T) {
+	tt := assert.New(t)
+
+	h, err := mlog.NewErrorTracker(nil)
+	tt.NoError(err)
+	defer h.Close()
+	logger := slog.New(h)
+
+	for i := 0; i < 3; i++ {
+		logger.Error("open failed", "err", &fs.PathError{Op: "open", Path: fmt.Sprint("/f", i), Err: fs.ErrNotExist}, "i", i)
+	}
+	logger.Error("open failed", "err", errors.New("other type"))
+	logger.Warn("ignored")
+
+	groups := h.Groups()
+	tt.Len(groups, 2)
+	tt.EqualValues("*errors.errorString", groups[0].ErrorType) // most recently seen first
+	tt.EqualValues(1, groups[0].Count)
+	g := groups[1]
+	tt.EqualValues(3, g.Count)
+	tt.EqualValues("open failed", g.Message)
+	tt.EqualValues("*fs.PathError", g.ErrorType)
+	tt.Contains(g.Function, "Test__ErrorTracker__Groups")
+	tt.EqualValues("open /f2: file does not exist", g.Sample.Error)
+	tt.EqualValues(2, g.Sample.Attrs["i"])
+	tt.False(g.FirstSeen.After(g.LastSeen))
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "?fingerprint=" + g.Fingerprint)
+	tt.NoError(err)
+	defer resp.Body.Close()
+	tt.EqualValues(http.StatusOK, resp.StatusCode)
+	data := map[string]any{}
+	tt.NoError(json.NewDecoder(resp.Body).Decode(&data))
+	tt.EqualValues(3, data["count"])
+	tt.EqualValues("*fs.PathError", data["error_type"])
+
+	resp, err = http.Get(server.URL + "?limit=1")
+	tt.NoError(err)
+	defer resp.Body.Close()
+	list := []any{}
+	tt.NoError(json.NewDecoder(resp.Body).Decode(&list))
+	tt.Len(list, 1)
+}
+
+func Test__ErrorTracker__Persistence(t *testing.T) {
+	tt := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "errors.json")
+	logError := func(logger *slog.Logger) {
+		logger.Error("persistent", "err", os.ErrPermission)
+	}
+
+	h, err := mlog.NewErrorTracker(&mlog.ErrorTrackerOptions{Path: path})
+	tt.NoError(err)
+	logError(slog.New(h))
+	logError(slog.New(h))
+	tt.NoError(h.Close())
+	tt.NoError(h.Close())
+
+	h, err = mlog.NewErrorTracker(&mlog.ErrorTrackerOptions{Path: path}) // restart
+	tt.NoError(err)
+	defer h.Close()
+	tt.Len(h.Groups(), 1)
+	logError(slog.New(h))
+	groups := h.Groups()
+	tt.Len(groups, 1)
+	tt.EqualValues(3, groups[0].Count)
+
+	tt.NoError(os.WriteFile(path, []byte("broken"), 0o600))
+	_, err = mlog.NewErrorTracker(&mlog.ErrorTrackerOptions{Path: path})
+	tt.Error(err)
+}
+
+func Test__ErrorTracker__MaxGroups(t *testing.T) {
+	tt := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "errors.json")
+	h, err := mlog.NewErrorTracker(&mlog.ErrorTrackerOptions{Path: path, MaxGroups: 2})
+	tt.NoError(err)
+	start := time.Now()
+	for i, msg := range []string{"a", "b", "a", "c"} {
+		tt.NoError(h.Handle(context.Background(), slog.NewRecord(start.Add(time.Duration(i)*time.Second), slog.LevelError, msg, 0)))
+	}
+	groups := h.Groups()
+	tt.Len(groups, 2) // "b" is the least recently seen
+	tt.EqualValues("c", groups[0].Message)
+	tt.EqualValues("a", groups[1].Message)
+	tt.EqualValues(2, groups[1].Count)
+	tt.NoError(h.Close())
+
+	h, err = mlog.NewErrorTracker(&mlog.ErrorTrackerOptions{Path: path, MaxGroups: 1}) // restart with the lower limit
+	tt.NoError(err)
+	defer h.Close()
+	groups = h.Groups()
+	tt.Len(groups, 1)
+	tt.EqualValues("c", groups[0].Message)
+}
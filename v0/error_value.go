@@ -0,0 +1,206 @@
+package mlog
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime"
+)
+
+// ErrorInfo is a structured representation of the error value,
+// used instead of Error() text if [HumanReadableHandlerOptions.ErrorDetails] is set.
+type ErrorInfo struct {
+	// Msg is a result of Error() call
+	Msg string `json:"msg"`
+	// Type is a Go type name of the error, like "*fs.PathError"
+	Type string `json:"type"`
+	// Chain contains wrapped errors, one for errors created by fmt.Errorf("...%w...")
+	// or several for errors created by [errors.Join]
+	Chain []*ErrorInfo `json:"chain,omitempty"`
+}
+
+// NewErrorInfo returns structured representation of the error with whole unwrap chain.
+func NewErrorInfo(err error) *ErrorInfo {
+	return newErrorInfo(err, 0, DefaultMaxAttrDepth)
+}
+
+func newErrorInfo(err error, depth, maxDepth int) *ErrorInfo {
+	rv := &ErrorInfo{
+		Msg:  err.Error(),
+		Type: fmt.Sprintf("%T", err),
+	}
+	if depth >= maxDepth {
+		return rv
+	}
+	for _, e := range unwrapErrors(err) {
+		if e != nil {
+			rv.Chain = append(rv.Chain, newErrorInfo(e, depth+1, maxDepth))
+		}
+	}
+	return rv
+}
+
+// unwrapErrors returns errors directly wrapped by err.
+func unwrapErrors(err error) []error {
+	switch e := err.(type) { //nolint:errorlint // only direct wrapping is interesting here
+	case interface{ Unwrap() error }:
+		if u := e.Unwrap(); u != nil {
+			return []error{u}
+		}
+	case interface{ Unwrap() []error }:
+		return e.Unwrap()
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+type stackError struct {
+	err     error
+	callers []uintptr
+}
+
+func (e *stackError) Error() string      { return e.err.Error() }
+func (e *stackError) Unwrap() error      { return e.err }
+func (e *stackError) Callers() []uintptr { return e.callers }
+
+// WithStack returns error which wraps err and carries stack trace of the WithStack() call.
+// The stack trace can be extracted by [ErrorStack].
+// If err is nil, WithStack returns nil.
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &stackError{
+		err:     err,
+		callers: callers(3), //nolint:gomnd // skip runtime.Callers, callers() and WithStack()
+	}
+}
+
+// ErrorStack returns program counters of the stack trace, carried by err or by any error in its unwrap chain.
+// Errors created by [WithStack] or any error with Callers() []uintptr method are supported,
+// as well as errors with StackTrace() method which returns slice of uintptr-based values
+// (like github.com/pkg/errors does). The deepest stack trace in the chain is returned,
+// because it is closer to the origin of the error. Nil is returned if no stack trace found.
+func ErrorStack(err error) []uintptr {
+	return errorStack(err, 0)
+}
+
+func errorStack(err error, depth int) []uintptr {
+	if err == nil || depth >= DefaultMaxAttrDepth {
+		return nil
+	}
+	for _, e := range unwrapErrors(err) {
+		if rv := errorStack(e, depth+1); rv != nil {
+			return rv
+		}
+	}
+	return stackOf(err)
+}
+
+func stackOf(err error) []uintptr {
+	if e, ok := err.(interface{ Callers() []uintptr }); ok { //nolint:errorlint // chain is walked by caller
+		return e.Callers()
+	}
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil
+	}
+	out := m.Type().Out(0)
+	if out.Kind() != reflect.Slice || out.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	st := m.Call(nil)[0]
+	rv := make([]uintptr, st.Len())
+	for i := range rv {
+		rv[i] = uintptr(st.Index(i).Uint())
+	}
+	return rv
+}
+
+// StackSources decodes program counters of the stack trace, see [DecodeSource].
+func StackSources(pcs []uintptr) []*slog.Source {
+	rv := make([]*slog.Source, 0, len(pcs))
+	fs := runtime.CallersFrames(pcs)
+	for {
+		f, more := fs.Next()
+		if f.Function != "" || f.File != "" {
+			rv = append(rv, &slog.Source{
+				Function: f.Function,
+				File:     f.File,
+				Line:     f.Line,
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	return rv
+}
+
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip, pcs)
+	return pcs[:n]
+}
+
+// logSiteStack returns stack trace of the log call, or nil if handler was called
+// from another goroutine (asynchronously) and the log call frame can't be found.
+func logSiteStack(pc uintptr) []uintptr {
+	if pc == 0 {
+		return nil
+	}
+	pcs := callers(1)
+	for i := range pcs {
+		if pcs[i] == pc {
+			return pcs[i:]
+		}
+	}
+	return nil
+}
+
+// recordStack returns the stack trace, carried by first error attribute of the record,
+// or the stack trace of the log call.
+func recordStack(r *slog.Record) []uintptr {
+	var rv []uintptr
+	r.Attrs(func(a slog.Attr) bool {
+		if err, ok := a.Value.Any().(error); ok && a.Value.Kind() == slog.KindAny {
+			rv = ErrorStack(err)
+		}
+		return rv == nil
+	})
+	if rv == nil {
+		rv = logSiteStack(r.PC)
+	}
+	return rv
+}
+
+// recordError returns the first error attribute of the record or nil.
+func recordError(r *slog.Record) error {
+	var rv error
+	r.Attrs(func(a slog.Attr) bool {
+		if err, ok := a.Value.Any().(error); ok && a.Value.Kind() == slog.KindAny {
+			rv = err
+		}
+		return rv == nil
+	})
+	return rv
+}
+
+// formatStack returns the stack trace as list of "function file:line" strings.
+func formatStack(pcs []uintptr) []string {
+	ss := StackSources(pcs)
+	rv := make([]string, len(ss))
+	for i := range ss {
+		rv[i] = fmt.Sprintf("%s %s:%d", ss[i].Function, ss[i].File, ss[i].Line)
+	}
+	return rv
+}
+
+// appendStack appends the stack trace as indented block, like Go runtime prints it at panic.
+func appendStack(buf []byte, pcs []uintptr) []byte {
+	for _, s := range StackSources(pcs) {
+		buf = fmt.Appendf(buf, "%s%s\n%s%s%s:%d\n", stackIndent, s.Function, stackIndent, stackIndent, s.File, s.Line)
+	}
+	return buf
+}
@@ -0,0 +1,89 @@
+package mlog_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__ErrorValue__Details(t *testing.T) {
+	tt := assert.New(t)
+
+	_, pathErr := os.Open("/nonexistent/" + t.Name())
+	err := fmt.Errorf("open config: %w", errors.Join(pathErr, fs.ErrPermission))
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, &mlog.HumanReadableHandlerOptions{ErrorDetails: true}))
+	logger.Error("failed", "err", err)
+
+	svData := hrAttrs(t, svWriter)
+	tt.EqualValues(err.Error(), JqGetString(svData, ".err.msg"))
+	tt.EqualValues("*fmt.wrapError", JqGetString(svData, ".err.type"))
+	tt.EqualValues("*errors.joinError", JqGetString(svData, ".err.chain[0].type"))
+	tt.EqualValues("*fs.PathError", JqGetString(svData, ".err.chain[0].chain[0].type"))
+	tt.EqualValues(fs.ErrPermission.Error(), JqGetString(svData, ".err.chain[0].chain[1].msg"))
+}
+
+func Test__ErrorValue__DetailsLimit(t *testing.T) {
+	tt := assert.New(t)
+
+	err := fmt.Errorf("short: %w", errors.New(strings.Repeat("x", 100)))
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, &mlog.HumanReadableHandlerOptions{
+		ErrorDetails: true,
+		MaxAttrSize:  16,
+	}))
+	logger.Error("failed", "err", err)
+
+	svData := hrAttrs(t, svWriter)
+	tt.EqualValues("short: xxxxxxxxx...!TRUNCATED", JqGetString(svData, ".err.msg"))
+	tt.EqualValues(strings.Repeat("x", 16)+"...!TRUNCATED", JqGetString(svData, ".err.chain[0].msg"))
+}
+
+func Test__ErrorValue__StackFromError(t *testing.T) {
+	tt := assert.New(t)
+
+	err := fmt.Errorf("wrapped: %w", mlog.WithStack(errors.New("origin")))
+	stack := mlog.StackSources(mlog.ErrorStack(err))
+	tt.NotEmpty(stack)
+	tt.True(strings.HasSuffix(stack[0].Function, t.Name()))
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, &mlog.HumanReadableHandlerOptions{StackTraceLevel: slog.LevelError}))
+	logger.Error("failed", "err", err)
+
+	svData := hrAttrs(t, svWriter)
+	tt.EqualValues(err.Error(), svData["err"])
+	tt.True(strings.HasPrefix(JqGetString(svData, ".stack[0]"), stack[0].Function+" "))
+}
+
+func Test__ErrorValue__StackFromLogSite(t *testing.T) {
+	tt := assert.New(t)
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, &mlog.HumanReadableHandlerOptions{
+		StackTraceLevel: slog.LevelError,
+		PrintStackTrace: true,
+	}))
+	logger.Warn("no stack expected")
+	logger.Error("stack expected")
+
+	lines := strings.Split(strings.TrimSuffix(svWriter.String(), "\n"), "\n")
+	tt.Greater(len(lines), 3)
+	tt.Contains(lines[0], "no stack expected")
+	tt.Contains(lines[1], "stack expected")
+	tt.NotContains(lines[1], mlog.AttrsJSONprefix)
+	tt.True(strings.HasPrefix(lines[2], "    "))
+	tt.True(strings.HasSuffix(lines[2], t.Name()))
+	tt.True(strings.HasPrefix(lines[3], "        "))
+	tt.Contains(lines[3], "error_value__test.go:")
+}
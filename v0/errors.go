@@ -4,4 +4,15 @@ import (
 	"errors"
 )
 
-var Error = errors.New("")
+var (
+	Error                 = errors.New("")
+	ErrInvalidTraceParent = errors.New("invalid traceparent")
+	ErrMessageTooLarge    = errors.New("message too large")
+	ErrClosed             = errors.New("writer is closed")
+	ErrBufferFull         = errors.New("buffer is full")
+	ErrUnexpectedStatus   = errors.New("unexpected HTTP status")
+	ErrDocumentsRejected  = errors.New("documents rejected")
+	ErrCircuitOpen        = errors.New("circuit breaker is open")
+	ErrInvalidLevelSpec   = errors.New("invalid level spec")
+	ErrNotRegistered      = errors.New("not registered")
+)
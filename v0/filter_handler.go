@@ -0,0 +1,83 @@
+package mlog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// FilterHandler is a [slog.Handler] wrapper, which passes to the next handler only records,
+// accepted by the filter function, for example to route records with some message or attribute
+// to the dedicated destination.
+type FilterHandler struct {
+	filter func(ctx context.Context, r slog.Record) bool
+	attrs  []slog.Attr
+	next   slog.Handler
+}
+
+// NewFilterHandler creates a FilterHandler with the given filter function.
+// The filter gets the record with attributes, added by WithAttrs, followed by the record's own attributes;
+// names of groups, added by WithGroup, are not applied to them.
+func NewFilterHandler(next slog.Handler, filter func(ctx context.Context, r slog.Record) bool) *FilterHandler {
+	return &FilterHandler{
+		filter: filter,
+		next:   next,
+	}
+}
+
+// Enabled reports whether the next handler handles records at the given level.
+// Implements [slog.Handler] interface.
+func (h *FilterHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record to the next handler if the filter accepts it.
+// Implements [slog.Handler] interface.
+func (h *FilterHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	fr := r
+	if len(h.attrs) != 0 {
+		fr = slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		fr.AddAttrs(h.attrs...)
+		r.Attrs(func(a slog.Attr) bool {
+			fr.AddAttrs(a)
+			return true
+		})
+	}
+	if !h.filter(ctx, fr) {
+		return nil
+	}
+	return h.next.Handle(ctx, r) //nolint:wrapcheck
+}
+
+// WithAttrs returns a new FilterHandler whose next handler has given attributes.
+// Implements [slog.Handler] interface.
+func (h *FilterHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &FilterHandler{
+		filter: h.filter,
+		attrs:  append(slices.Clip(h.attrs), aa...),
+		next:   h.next.WithAttrs(aa),
+	}
+}
+
+// WithGroup returns a new FilterHandler whose next handler has the given group.
+// Implements [slog.Handler] interface.
+func (h *FilterHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &FilterHandler{
+		filter: h.filter,
+		attrs:  h.attrs,
+		next:   h.next.WithGroup(name),
+	}
+}
+
+// Flush flushes the next handler, if it implements [Flusher].
+func (h *FilterHandler) Flush(ctx context.Context) error {
+	return flushNext(ctx, h.next)
+}
+
+// Close closes the next handler, if it implements [Closer].
+func (h *FilterHandler) Close() error {
+	return closeNext(h.next)
+}
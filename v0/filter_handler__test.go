@@ -0,0 +1,36 @@
+package mlog_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__FilterHandler__Attrs(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	handler := mlog.NewFilterHandler(mlog.NewHumanReadableHandler(buf, nil), func(_ context.Context, r slog.Record) bool {
+		found := false
+		r.Attrs(func(a slog.Attr) bool {
+			found = a.Key == "component" && a.Value.String() == "db"
+			return !found
+		})
+		return found
+	})
+	logger := slog.New(handler)
+
+	logger.Info("skipped")
+	logger.Info("by record", "component", "db")
+	logger.With("component", "db").WithGroup("g").Info("by handler", "n", 1)
+	logger.With("component", "api").Info("other")
+
+	tt.EqualValues(2, strings.Count(buf.String(), "\n"))
+	tt.Contains(buf.String(), "by record")
+	tt.Contains(buf.String(), `by handler  ATTRS={"component":"db","g":{"n":1}}`)
+}
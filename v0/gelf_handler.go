@@ -0,0 +1,273 @@
+package mlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"regexp"
+	"time"
+)
+
+// GELFCompression is a compression method of GELF messages, sent over UDP.
+type GELFCompression int
+
+const (
+	GELFCompressionNone GELFCompression = iota
+	GELFCompressionGzip
+	GELFCompressionZlib
+)
+
+type GELFHandlerOptions struct {
+	// Network is "udp" or "tcp". If empty, "udp" is used.
+	Network string
+	// Addr is the GELF input address, like "graylog:12201".
+	Addr string
+	// DialTimeout limits time to connect to the server. If zero, [DefaultDialTimeout] is used.
+	DialTimeout time.Duration
+
+	// Compression of UDP messages. TCP messages are never compressed, because GELF TCP input doesn't support it.
+	Compression GELFCompression
+	// ChunkSize is the maximum UDP datagram size, larger messages are chunked.
+	// If zero, [DefaultGELFChunkSize] is used.
+	ChunkSize int
+
+	// Host is the "host" field of messages. If empty, [os.Hostname] is used.
+	Host string
+
+	// AddSource causes the handler to add "_file", "_line" and "_function" fields
+	// with the source code position of the log statement.
+	AddSource bool
+
+	// StackTraceLevel enables stack trace in the "full_message" field for records with level at or above given,
+	// see [HumanReadableHandlerOptions.StackTraceLevel]. If nil, stack traces are not sent.
+	StackTraceLevel slog.Leveler
+
+	// MaxAttrDepth and MaxAttrSize limit attribute values, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to log.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelInfo].
+	Level slog.Leveler
+}
+
+// GELFHandler is a [slog.Handler] that sends Records to Graylog as GELF 1.1 messages over UDP or TCP.
+// Level is sent as syslog severity (see [LevelToSeverity]), attributes are sent as additional fields
+// with "_" prefix, names of nested groups are joined by underscore. Attributes, whose names collide with
+// "_id" or fields of the handler, like "_level_name" or "_file", get "_" suffix.
+// UDP messages are chunked and optionally compressed, TCP messages are delimited by null byte.
+type GELFHandler struct {
+	opts  GELFHandlerOptions
+	state handlerState
+	conn  *lazyConn
+}
+
+// NewGELFHandler creates a GELFHandler, using the given options.
+func NewGELFHandler(opts *GELFHandlerOptions) *GELFHandler {
+	h := &GELFHandler{}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	if h.opts.Network == "" {
+		h.opts.Network = "udp"
+	}
+	if h.opts.ChunkSize <= gelfChunkHeaderSize {
+		h.opts.ChunkSize = DefaultGELFChunkSize
+	}
+	if h.opts.Host == "" {
+		h.opts.Host = hostname()
+	}
+	if h.opts.DialTimeout == 0 {
+		h.opts.DialTimeout = DefaultDialTimeout
+	}
+	h.state = newHandlerState(newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize))
+	h.conn = newLazyConn(h.opts.Network, h.opts.Addr, h.opts.DialTimeout)
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *GELFHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle encodes the Record as GELF message and sends it.
+// Implements [slog.Handler] interface.
+func (h *GELFHandler) Handle(_ context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
+	msg, err := json.Marshal(h.message(&r))
+	if err != nil {
+		return errors.Join(Error, err)
+	}
+	err = h.conn.write(func(conn net.Conn) error {
+		if isStreamConn(conn) {
+			_, err := conn.Write(append(msg, 0))
+			return err //nolint:wrapcheck
+		}
+		return h.writeUDP(conn, msg)
+	})
+	if err != nil {
+		return errors.Join(Error, err)
+	}
+	return nil
+}
+
+// message returns GELF message fields.
+func (h *GELFHandler) message(r *slog.Record) map[string]any {
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	rv := map[string]any{
+		"version":       "1.1",
+		"host":          h.opts.Host,
+		"short_message": r.Message,
+		"timestamp":     float64(t.UnixMicro()) / 1e6, //nolint:gomnd
+		"level":         int(LevelToSeverity(r.Level)),
+		"_level_name":   LevelString(r.Level),
+	}
+	if h.opts.StackTraceLevel != nil && r.Level >= h.opts.StackTraceLevel.Level() {
+		if stack := recordStack(r); len(stack) != 0 {
+			rv["full_message"] = string(appendStack([]byte(r.Message+"\n"), stack))
+		}
+	}
+	if h.opts.AddSource && r.PC != 0 {
+		source := DecodeSource(r.PC)
+		rv["_file"] = source.File
+		rv["_line"] = source.Line
+		rv["_function"] = source.Function
+	}
+	attrs, _ := h.state.tree(r)
+	flattenTree(attrs, "", "_", func(key string, value any) {
+		rv[gelfFieldName(key)] = gelfFieldValue(value)
+	})
+	return rv
+}
+
+// writeUDP sends the message as one datagram or as a sequence of chunks.
+func (h *GELFHandler) writeUDP(conn net.Conn, msg []byte) error {
+	msg, err := h.compress(msg)
+	if err != nil {
+		return err
+	}
+	if len(msg) <= h.opts.ChunkSize {
+		_, err = conn.Write(msg)
+		return err //nolint:wrapcheck
+	}
+
+	dataSize := h.opts.ChunkSize - gelfChunkHeaderSize
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > maxGELFChunks {
+		return fmt.Errorf("%w: %d bytes requires %d chunks", ErrMessageTooLarge, len(msg), count)
+	}
+	chunk := make([]byte, 0, h.opts.ChunkSize)
+	chunk = append(chunk, 0x1e, 0x0f) //nolint:gomnd // chunked GELF magic bytes
+	chunk = append(chunk, make([]byte, 8)...)
+	if _, err := rand.Read(chunk[2:10]); err != nil { // message ID
+		return err //nolint:wrapcheck
+	}
+	chunk = append(chunk, 0, byte(count))
+	for i := 0; i < count; i++ {
+		chunk[10] = byte(i)
+		end := min((i+1)*dataSize, len(msg))
+		if _, err := conn.Write(append(chunk[:gelfChunkHeaderSize], msg[i*dataSize:end]...)); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+	return nil
+}
+
+func (h *GELFHandler) compress(msg []byte) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+	switch h.opts.Compression {
+	case GELFCompressionGzip:
+		w = gzip.NewWriter(&buf)
+	case GELFCompressionZlib:
+		w = zlib.NewWriter(&buf)
+	case GELFCompressionNone:
+		return msg, nil
+	}
+	if _, err := w.Write(msg); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if err := w.Close(); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return buf.Bytes(), nil
+}
+
+// WithAttrs returns a new GELFHandler whose attributes consists of h's attributes followed by attrs.
+// Implements [slog.Handler] interface.
+func (h *GELFHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &GELFHandler{
+		opts:  h.opts,
+		state: h.state.withAttrs(aa),
+		conn:  h.conn,
+	}
+}
+
+// WithGroup returns a new GELFHandler with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *GELFHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &GELFHandler{
+		opts:  h.opts,
+		state: h.state.withGroup(name),
+		conn:  h.conn,
+	}
+}
+
+// Close closes the connection to the server.
+// The connection is shared by all handlers, derived by WithAttrs and WithGroup.
+func (h *GELFHandler) Close() error {
+	return h.conn.close()
+}
+
+// -----------------------------------------------------------------------------
+
+var gelfInvalidChars = regexp.MustCompile(`[^\w.\-]`) //nolint:gochecknoglobals
+
+// gelfReservedFields are additional fields, which are reserved by GELF ("_id") or written by the handler.
+var gelfReservedFields = map[string]bool{ //nolint:gochecknoglobals
+	"_id": true, "_level_name": true, "_file": true, "_line": true, "_function": true,
+}
+
+// gelfFieldName returns additional field name, which matches ^_[\w\.\-]*$ and is not one of reserved fields,
+// those get underscore suffix, like "_id_".
+func gelfFieldName(key string) string {
+	name := "_" + gelfInvalidChars.ReplaceAllString(key, "_")
+	if gelfReservedFields[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// gelfFieldValue returns numbers as is and other values as strings, GELF supports only them.
+func gelfFieldValue(v any) any {
+	switch v.(type) {
+	case int64, uint64, float64:
+		return v
+	}
+	return formatScalar(v)
+}
@@ -0,0 +1,175 @@
+package mlog_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+// readGELFUDP receives one GELF message, reassembling chunks and decompressing it.
+func readGELFUDP(t *testing.T, pc net.PacketConn) map[string]any {
+	t.Helper()
+	chunks := map[byte][]byte{}
+	var msg []byte
+	for msg == nil {
+		buf := make([]byte, 65536)
+		if err := pc.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf = buf[:n]
+		if buf[0] != 0x1e || buf[1] != 0x0f {
+			msg = buf
+			break
+		}
+		chunks[buf[10]] = buf[12:]
+		if len(chunks) == int(buf[11]) {
+			keys := make([]int, 0, len(chunks))
+			for k := range chunks {
+				keys = append(keys, int(k))
+			}
+			sort.Ints(keys)
+			for _, k := range keys {
+				msg = append(msg, chunks[byte(k)]...)
+			}
+		}
+	}
+
+	var rd io.Reader = bytes.NewReader(msg)
+	switch {
+	case msg[0] == 0x1f && msg[1] == 0x8b:
+		gz, err := gzip.NewReader(rd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rd = gz
+	case msg[0] == 0x78:
+		zr, err := zlib.NewReader(rd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rd = zr
+	}
+	rv := map[string]any{}
+	if err := json.NewDecoder(rd).Decode(&rv); err != nil {
+		t.Fatal(err)
+	}
+	return rv
+}
+
+func Test__GELFHandler__UDP_Gzip(t *testing.T) {
+	tt := assert.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	tt.NoError(err)
+	defer pc.Close()
+
+	h := mlog.NewGELFHandler(&mlog.GELFHandlerOptions{
+		Addr:            pc.LocalAddr().String(),
+		Compression:     mlog.GELFCompressionGzip,
+		Host:            "host1",
+		AddSource:       true,
+		StackTraceLevel: slog.LevelError,
+	})
+	defer h.Close()
+	logger := slog.New(h).With("id", 7, "level_name", "fake", "file", "fake.go").WithGroup("http")
+	logger.Error("request failed", "status", 502, "ok", false, "err", mlog.WithStack(errors.New("bad gateway")))
+
+	msg := readGELFUDP(t, pc)
+	tt.EqualValues("1.1", msg["version"])
+	tt.EqualValues("host1", msg["host"])
+	tt.EqualValues("request failed", msg["short_message"])
+	tt.EqualValues(3, msg["level"])
+	tt.EqualValues(7, msg["_id_"])
+	tt.EqualValues(502, msg["_http_status"])
+	tt.EqualValues("false", msg["_http_ok"])
+	tt.EqualValues("bad gateway", msg["_http_err"])
+	tt.Contains(msg["_file"], "gelf_handler__test.go")
+	tt.EqualValues("fake.go", msg["_file_"])
+	tt.EqualValues("ERROR", msg["_level_name"])
+	tt.EqualValues("fake", msg["_level_name_"])
+	tt.Contains(msg["full_message"], t.Name())
+	tt.Positive(msg["timestamp"])
+}
+
+func Test__GELFHandler__UDP_Chunked(t *testing.T) {
+	tt := assert.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	tt.NoError(err)
+	defer pc.Close()
+
+	h := mlog.NewGELFHandler(&mlog.GELFHandlerOptions{
+		Addr:        pc.LocalAddr().String(),
+		Compression: mlog.GELFCompressionZlib,
+		ChunkSize:   512,
+	})
+	defer h.Close()
+
+	payload := make([]byte, 8000)
+	for i := range payload {
+		payload[i] = byte('a' + i*7919%26)
+	}
+	slog.New(h).Info("big", "payload", string(payload))
+
+	msg := readGELFUDP(t, pc)
+	tt.EqualValues("big", msg["short_message"])
+	tt.EqualValues(string(payload), msg["_payload"])
+}
+
+func Test__GELFHandler__TCP(t *testing.T) {
+	tt := assert.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tt.NoError(err)
+	defer ln.Close()
+	received := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		for {
+			msg, err := rd.ReadString(0)
+			if err != nil {
+				return
+			}
+			received <- strings.TrimSuffix(msg, "\x00")
+		}
+	}()
+
+	h := mlog.NewGELFHandler(&mlog.GELFHandlerOptions{Network: "tcp", Addr: ln.Addr().String(), Compression: mlog.GELFCompressionGzip})
+	defer h.Close()
+	logger := slog.New(mlog.NewMultipleHandler(nil, h))
+	logger.Warn("first")
+	logger.Info("second", "k", "v")
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case raw := <-received:
+			msg := map[string]any{}
+			tt.NoError(json.Unmarshal([]byte(raw), &msg))
+			tt.EqualValues(expected, msg["short_message"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package mlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sort"
+	"strconv"
+)
+
+type jsonTree map[string]any
+
+type group struct {
+	name  string
+	attrs jsonTree
+}
+
+// handlerState keeps groups and attributes, added to the handler by WithGroup and WithAttrs,
+// in the JSON-safe form. It is shared by all mlog handlers, which render attributes.
+type handlerState struct {
+	groups []group
+	enc    valueEncoder
+}
+
+func newHandlerState(enc valueEncoder) handlerState {
+	return handlerState{
+		enc: enc,
+		groups: []group{{ // group[0] always exists, has no name and used
+			attrs: jsonTree{}, // to store non-groupped attrs
+		}},
+	}
+}
+
+func (s handlerState) clone() handlerState {
+	rv := handlerState{
+		enc:    s.enc,
+		groups: make([]group, len(s.groups)),
+	}
+	for i := range s.groups {
+		rv.groups[i].name = s.groups[i].name
+		rv.groups[i].attrs = maps.Clone(s.groups[i].attrs)
+	}
+	return rv
+}
+
+// withAttrs returns a copy of state whose innermost group has given attributes.
+func (s handlerState) withAttrs(aa []slog.Attr) handlerState {
+	rv := s.clone()
+	idx := len(rv.groups) - 1
+	for k := range aa {
+		rv.enc.addAttr(rv.groups[idx].attrs, aa[k], idx)
+	}
+	return rv
+}
+
+// withGroup returns a copy of state with the given group appended to existing groups.
+func (s handlerState) withGroup(name string) handlerState {
+	rv := s.clone()
+	rv.groups = append(rv.groups, group{
+		name:  name,
+		attrs: jsonTree{},
+	})
+	return rv
+}
+
+// tree returns the attributes of the state and the record as a tree.
+// Record attributes are placed into the innermost group, returned as ptr.
+func (s handlerState) tree(r *slog.Record) (root, ptr jsonTree) {
+	root = jsonTree{}
+	ptr = root // pointer to group (or tree root) to store record attributes
+	for i := range s.groups {
+		if s.groups[i].name != "" {
+			ptr[s.groups[i].name] = jsonTree{}
+			ptr = ptr[s.groups[i].name].(jsonTree) //revive:disable:unchecked-type-assertion // because created with right type in the previous line
+		}
+		maps.Copy(ptr, s.groups[i].attrs)
+	}
+
+	if r != nil {
+		r.Attrs(func(a slog.Attr) bool {
+			s.enc.addAttr(ptr, a, len(s.groups)-1)
+			return true
+		})
+	}
+	return root, ptr
+}
+
+// -----------------------------------------------------------------------------
+
+// flattenTree calls fn for each leaf of the tree in the sorted order,
+// keys of nested groups are joined by sep.
+func flattenTree(tree jsonTree, prefix, sep string, fn func(key string, value any)) {
+	for _, k := range sortedKeys(tree) {
+		key := k
+		if prefix != "" {
+			key = prefix + sep + k
+		}
+		if sub, ok := tree[k].(jsonTree); ok {
+			flattenTree(sub, key, sep, fn)
+			continue
+		}
+		fn(key, tree[k])
+	}
+}
+
+// sortedKeys returns keys of the tree in the same order as [json.Marshal] does.
+func sortedKeys(tree jsonTree) []string {
+	rv := make([]string, 0, len(tree))
+	for k := range tree {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// formatScalar returns string representation of the JSON-safe value,
+// composite values are rendered as JSON.
+func formatScalar(v any) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vv
+	case bool:
+		return strconv.FormatBool(vv)
+	case int64:
+		return strconv.FormatInt(vv, 10)
+	case uint64:
+		return strconv.FormatUint(vv, 10)
+	case float64:
+		return strconv.FormatFloat(vv, 'g', -1, 64)
+	case json.RawMessage:
+		var s string
+		if json.Unmarshal(vv, &s) == nil {
+			return s
+		}
+		return string(vv)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
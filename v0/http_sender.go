@@ -0,0 +1,71 @@
+package mlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// httpSender posts encoded batches to the collector. It is used by the HTTP-based handlers.
+type httpSender struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+	gzip    bool
+	// fullResponse disables the limit of the successful response body, which must be parsed entirely,
+	// like the bulk response of Elasticsearch. Its size is proportional to the size of the batch.
+	fullResponse bool
+}
+
+// post sends the body and returns the response body. 4xx statuses, except 408 and 429,
+// are returned as [permanentError], because retry will not fix them.
+func (s httpSender) post(ctx context.Context, contentType string, body []byte) ([]byte, error) {
+	var rd io.Reader = bytes.NewReader(body)
+	if s.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		rd = &buf
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, rd)
+	if err != nil {
+		return nil, permanentError{err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	defer resp.Body.Close()
+	var respReader io.Reader = resp.Body
+	if !s.fullResponse || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respReader = io.LimitReader(resp.Body, maxResponseSize)
+	}
+	respBody, err := io.ReadAll(respReader)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return respBody, nil
+	}
+	err = fmt.Errorf("%w: %s: %s", ErrUnexpectedStatus, resp.Status, bytes.TrimSpace(respBody[:min(len(respBody), maxErrorBodySize)]))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return nil, permanentError{err}
+	}
+	return nil, err
+}
@@ -7,18 +7,14 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"maps"
-	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
-type jsonTree map[string]any
-
-type group struct {
-	name  string
-	attrs jsonTree
-}
-
 type HumanReadableHandlerOptions struct {
 	// AddSource causes the handler to compute the source code position
 	// of the log statement and add source file name and line No to the output as plain text.
@@ -28,8 +24,42 @@ type HumanReadableHandlerOptions struct {
 	// of the log statement and add a "source" attribute to the ATTRS JSON block.
 	AddSourceToAttrs bool
 
+	// SourceFormat defines how the source code position is shown if AddSource is set.
+	// Default is [SourceBaseName].
+	SourceFormat SourceFormat
+
+	// SourceHyperlinks causes the handler to wrap the source code position into
+	// OSC 8 terminal escape sequence, which makes it a clickable link to the file.
+	SourceHyperlinks bool
+
 	UseLocalTZ bool
 
+	// PromotedAttrs are keys of top-level attributes, whose values are shown as plain text before the message
+	// instead of the ATTRS JSON block, for example "GET /users 200 1.5ms" for [AccessLogPromotedAttrs].
+	// Values with spaces, quotes or control characters are quoted, like "/a\nb".
+	PromotedAttrs []string
+
+	// MaxAttrDepth limits nesting of groups, maps and slices in the attribute value.
+	// If zero, [DefaultMaxAttrDepth] is used.
+	MaxAttrDepth int
+
+	// MaxAttrSize limits size (in bytes) of the string or JSON representation of a single attribute value,
+	// longer values are truncated. If zero, [DefaultMaxAttrSize] is used.
+	MaxAttrSize int
+
+	// ErrorDetails causes the handler to render error values as [ErrorInfo] objects
+	// with type names and unwrap chain instead of plain Error() text.
+	ErrorDetails bool
+
+	// StackTraceLevel enables stack trace output for records with level at or above given.
+	// The stack trace is extracted from the first error attribute of the record (see [ErrorStack])
+	// or captured at the log call site. If nil, stack traces are not shown.
+	StackTraceLevel slog.Leveler
+
+	// PrintStackTrace causes the handler to print the stack trace as indented block after the log line
+	// (useful in development) instead of the "stack" attribute of the ATTRS JSON block.
+	PrintStackTrace bool
+
 	// Level reports the minimum level to log.
 	// Levels with lower levels are discarded.
 	// If nil, the Handler uses [slog.LevelInfo].
@@ -40,10 +70,10 @@ type HumanReadableHandlerOptions struct {
 // timestamp, level, message as plain test, and sequence of key=value pairs in the JSON format and followed by a newline.
 type HumanReadableHandler struct {
 	opts   HumanReadableHandlerOptions
-	groups []group
-	// TODO: state for WithGroup and WithAttrs
-	mu  *sync.Mutex
-	out io.Writer
+	state  handlerState
+	logger string // the logger name, shown before the message, see [LoggerKey]
+	mu     *sync.Mutex
+	out    io.Writer
 }
 
 // NewHumanReadableHandler creates a HumanReadableHandler that writes to w, using the given options.
@@ -53,9 +83,6 @@ func NewHumanReadableHandler(w io.Writer, opts *HumanReadableHandlerOptions) *Hu
 	h := &HumanReadableHandler{
 		out: w,
 		mu:  &sync.Mutex{},
-		groups: []group{{ // group[0] always exists, has no name and used
-			attrs: jsonTree{}, // to store non-groupped attrs
-		}},
 	}
 	if opts != nil {
 		h.opts = *opts
@@ -63,6 +90,9 @@ func NewHumanReadableHandler(w io.Writer, opts *HumanReadableHandlerOptions) *Hu
 	if h.opts.Level == nil {
 		h.opts.Level = slog.LevelInfo
 	}
+	enc := newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize)
+	enc.errorDetails = h.opts.ErrorDetails
+	h.state = newHandlerState(enc)
 	return h
 }
 
@@ -71,27 +101,31 @@ func (h *HumanReadableHandler) Copy() *HumanReadableHandler {
 	defer h.mu.Unlock()
 	rv := &HumanReadableHandler{
 		opts:   h.opts,
+		state:  h.state.clone(),
+		logger: h.logger,
 		out:    h.out,
 		mu:     h.mu,
-		groups: make([]group, len(h.groups)),
-	}
-	for i := range h.groups {
-		rv.groups[i].name = h.groups[i].name
-		rv.groups[i].attrs = maps.Clone(h.groups[i].attrs)
 	}
 	return rv
 }
 
 // Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// The level override, stored in ctx by [WithLevel], enables lower levels than the handler's one.
 // Implements [slog.Handler] interface.
-func (h *HumanReadableHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return level >= h.opts.Level.Level()
+func (h *HumanReadableHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level() || overrideEnabled(ctx, level)
 }
 
 // Handle handles the Record.
 // It will only be called when Enabled(...) returns true.
+// Panic, raised while record processing, is recovered and returned as error.
 // Implements [slog.Handler] interface.
-func (h *HumanReadableHandler) Handle(_ context.Context, r slog.Record) error { //nolint:gocritic
+func (h *HumanReadableHandler) Handle(_ context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
 	buf := make([]byte, 0, LogLineBuffSize)
 	if !r.Time.IsZero() {
 		if h.opts.UseLocalTZ {
@@ -104,32 +138,39 @@ func (h *HumanReadableHandler) Handle(_ context.Context, r slog.Record) error {
 
 	if h.opts.AddSource && r.PC != 0 {
 		source := DecodeSource(r.PC)
-		buf = fmt.Appendf(buf, "[%s:%d]  ", filepath.Base(source.File), source.Line)
+		location := FormatSource(source, h.opts.SourceFormat)
+		if h.opts.SourceHyperlinks {
+			buf = append(buf, '[')
+			buf = appendHyperlink(buf, source, location)
+			buf = append(buf, "]  "...)
+		} else {
+			buf = fmt.Appendf(buf, "[%s]  ", location)
+		}
 	} else {
 		buf = fmt.Appendf(buf, "--  ")
 	}
 
-	buf = append(buf, r.Message...)
+	attrs, _ := h.state.tree(&r)
 
-	attrs := jsonTree{}
-	ptr := attrs // pointer to group (or tree root) to store record attributes
-	for i := range h.groups {
-		if h.groups[i].name != "" {
-			ptr[h.groups[i].name] = jsonTree{}
-			ptr = ptr[h.groups[i].name].(jsonTree) //revive:disable:unchecked-type-assertion // because created with right type in the previous line
-		}
-		maps.Copy(ptr, h.groups[i].attrs)
+	if h.logger != "" {
+		buf = append(buf, '<')
+		buf = append(buf, h.logger...)
+		buf = append(buf, ">  "...)
 	}
+	buf = h.appendPromoted(buf, &r, attrs)
+	buf = append(buf, r.Message...)
 
 	if h.opts.AddSourceToAttrs && r.PC != 0 {
 		attrs[slog.SourceKey] = DecodeSource(r.PC)
 	}
 
-	// fill Attrs from records
-	r.Attrs(func(a slog.Attr) bool {
-		ptr[a.Key] = a.Value.Any()
-		return true
-	})
+	var stack []uintptr
+	if h.opts.StackTraceLevel != nil && r.Level >= h.opts.StackTraceLevel.Level() {
+		stack = recordStack(&r)
+		if len(stack) != 0 && !h.opts.PrintStackTrace {
+			attrs[StackKey] = formatStack(stack)
+		}
+	}
 
 	// serialize and store JSON into buffer
 	if len(attrs) != 0 {
@@ -143,23 +184,77 @@ func (h *HumanReadableHandler) Handle(_ context.Context, r slog.Record) error {
 	}
 
 	buf = append(buf, "\n"...)
+	if h.opts.PrintStackTrace {
+		buf = appendStack(buf, stack)
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	_, err := h.out.Write(buf)
+	_, err = h.out.Write(buf)
 	if err != nil {
 		err = errors.Join(Error, err)
 	}
 	return err
 }
 
+// appendPromoted appends values of top-level attributes, listed in PromotedAttrs, and removes them from attrs.
+// Durations of the record's own attributes are shown in the [time.Duration] form, like "1.5ms".
+func (h *HumanReadableHandler) appendPromoted(buf []byte, r *slog.Record, attrs jsonTree) []byte {
+	if len(h.opts.PromotedAttrs) == 0 || len(attrs) == 0 {
+		return buf
+	}
+	var durations map[string]string
+	if len(h.state.groups) == 1 {
+		r.Attrs(func(a slog.Attr) bool {
+			if v := a.Value.Resolve(); v.Kind() == slog.KindDuration && slices.Contains(h.opts.PromotedAttrs, a.Key) {
+				if durations == nil {
+					durations = map[string]string{}
+				}
+				durations[a.Key] = v.Duration().String()
+			}
+			return true
+		})
+	}
+	promoted := false
+	for _, key := range h.opts.PromotedAttrs {
+		v, ok := attrs[key]
+		if !ok {
+			continue
+		}
+		if promoted {
+			buf = append(buf, ' ')
+		}
+		if d, ok := durations[key]; ok {
+			buf = append(buf, d...)
+		} else {
+			buf = append(buf, quotePromoted(formatScalar(v))...)
+		}
+		delete(attrs, key)
+		promoted = true
+	}
+	if promoted {
+		buf = append(buf, "  "...)
+	}
+	return buf
+}
+
 // WithAttrs returns a new HumanReadableHandler whose attributes consists of h's attributes followed by attrs.
+// The top-level string attribute [LoggerKey] is shown before the message instead of the ATTRS JSON block.
 // Implements [slog.Handler] interface.
 func (h *HumanReadableHandler) WithAttrs(aa []slog.Attr) slog.Handler {
 	hh := h.Copy()
-	idx := len(hh.groups) - 1
-	for k := range aa {
-		hh.groups[idx].attrs[aa[k].Key] = aa[k].Value.Any()
+	if len(hh.state.groups) == 1 {
+		rest := make([]slog.Attr, 0, len(aa))
+		for _, a := range aa {
+			if a.Key == LoggerKey && a.Value.Kind() == slog.KindString {
+				hh.logger = a.Value.String()
+				continue
+			}
+			rest = append(rest, a)
+		}
+		aa = rest
 	}
+	hh.state = hh.state.withAttrs(aa)
 	return hh
 }
 
@@ -169,12 +264,20 @@ func (h *HumanReadableHandler) WithGroup(name string) slog.Handler {
 	var hh *HumanReadableHandler
 	if name != "" {
 		hh = h.Copy()
-		hh.groups = append(hh.groups, group{
-			name:  name,
-			attrs: jsonTree{},
-		})
+		hh.state = hh.state.withGroup(name)
 	} else {
 		hh = h
 	}
 	return hh
 }
+
+// quotePromoted quotes the promoted value, if it is empty or has spaces, quotes or control characters,
+// so values like the request path, which come from the client, can't forge the line or its columns.
+func quotePromoted(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return r == '"' || unicode.IsSpace(r) || unicode.IsControl(r) || r == utf8.RuneError
+	}) < 0 {
+		return s
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,267 @@
+//go:build linux
+
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+type JournaldHandlerOptions struct {
+	// SocketPath is a path of the journald native protocol socket.
+	// If empty, [DefaultJournaldSocket] is used.
+	SocketPath string
+
+	// SyslogIdentifier is a value of the SYSLOG_IDENTIFIER field. If empty, the executable name is used.
+	SyslogIdentifier string
+
+	// AddSource causes the handler to add CODE_FILE, CODE_LINE and CODE_FUNC fields
+	// with the source code position of the log statement.
+	AddSource bool
+
+	// MaxAttrDepth and MaxAttrSize limit attribute values, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to log.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelInfo].
+	Level slog.Leveler
+}
+
+// JournaldHandler is a [slog.Handler] that sends Records to the systemd journal
+// by the native journal protocol, so the structure of the record is kept:
+// message is stored as MESSAGE, level as PRIORITY (see [LevelToSeverity]) and attributes
+// as fields with uppercased names, names of nested groups are joined by underscore. Attributes,
+// whose names collide with fields of the handler, like "message" or "priority", get "X_" prefix.
+// Entries, which are too large for one datagram, are passed through the memfd.
+type JournaldHandler struct {
+	opts  JournaldHandlerOptions
+	state handlerState
+	conn  *journaldConn
+}
+
+// NewJournaldHandler creates a JournaldHandler, using the given options.
+// If opts is nil, the default options are used.
+func NewJournaldHandler(opts *JournaldHandlerOptions) *JournaldHandler {
+	h := &JournaldHandler{}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	if h.opts.SocketPath == "" {
+		h.opts.SocketPath = DefaultJournaldSocket
+	}
+	if h.opts.SyslogIdentifier == "" {
+		h.opts.SyslogIdentifier = filepath.Base(os.Args[0])
+	}
+	h.state = newHandlerState(newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize))
+	h.conn = &journaldConn{
+		addr: &net.UnixAddr{Name: h.opts.SocketPath, Net: "unixgram"},
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *JournaldHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle sends the Record to the journal.
+// Implements [slog.Handler] interface.
+func (h *JournaldHandler) Handle(_ context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
+	buf := make([]byte, 0, LogLineBuffSize)
+	buf = appendJournalField(buf, "MESSAGE", r.Message)
+	buf = appendJournalField(buf, "PRIORITY", strconv.Itoa(int(LevelToSeverity(r.Level))))
+	buf = appendJournalField(buf, "SYSLOG_IDENTIFIER", h.opts.SyslogIdentifier)
+	if h.opts.AddSource && r.PC != 0 {
+		source := DecodeSource(r.PC)
+		buf = appendJournalField(buf, "CODE_FILE", source.File)
+		buf = appendJournalField(buf, "CODE_LINE", strconv.Itoa(source.Line))
+		buf = appendJournalField(buf, "CODE_FUNC", source.Function)
+	}
+	attrs, _ := h.state.tree(&r)
+	flattenTree(attrs, "", "_", func(key string, value any) {
+		buf = appendJournalField(buf, journalFieldName(key), formatScalar(value))
+	})
+
+	if err := h.conn.write(buf); err != nil {
+		return errors.Join(Error, err)
+	}
+	return nil
+}
+
+// WithAttrs returns a new JournaldHandler whose attributes consists of h's attributes followed by attrs.
+// Implements [slog.Handler] interface.
+func (h *JournaldHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &JournaldHandler{
+		opts:  h.opts,
+		state: h.state.withAttrs(aa),
+		conn:  h.conn,
+	}
+}
+
+// WithGroup returns a new JournaldHandler with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *JournaldHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &JournaldHandler{
+		opts:  h.opts,
+		state: h.state.withGroup(name),
+		conn:  h.conn,
+	}
+}
+
+// Close closes the journal socket.
+// The socket is shared by all handlers, derived by WithAttrs and WithGroup.
+func (h *JournaldHandler) Close() error {
+	return h.conn.close()
+}
+
+// -----------------------------------------------------------------------------
+
+// appendJournalField appends the field in the native journal protocol format,
+// values with newlines are written in the binary-safe form.
+func appendJournalField(buf []byte, name, value string) []byte {
+	buf = append(buf, name...)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf = append(buf, '=')
+		buf = append(buf, value...)
+		return append(buf, '\n')
+	}
+	buf = append(buf, '\n')
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(value)))
+	buf = append(buf, value...)
+	return append(buf, '\n')
+}
+
+// journalHandlerFields are fields, written by the handler, attributes can't override them.
+var journalHandlerFields = map[string]bool{ //nolint:gochecknoglobals
+	"MESSAGE": true, "PRIORITY": true, "SYSLOG_IDENTIFIER": true, "CODE_FILE": true, "CODE_LINE": true, "CODE_FUNC": true,
+}
+
+// journalFieldName returns a valid journal field name: uppercase letters, digits and underscores,
+// up to 64 characters, which doesn't start with underscore (reserved for trusted fields) or digit
+// and is not one of [journalHandlerFields].
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i := range name {
+		if (name[i] < 'A' || name[i] > 'Z') && (name[i] < '0' || name[i] > '9') {
+			name[i] = '_'
+		}
+	}
+	name = bytes.TrimLeft(name, "_")
+	if len(name) == 0 || (name[0] >= '0' && name[0] <= '9') || journalHandlerFields[string(name)] {
+		name = append([]byte(journalFieldPrefix), name...)
+	}
+	if len(name) > maxJournalFieldName {
+		name = name[:maxJournalFieldName]
+	}
+	return string(name)
+}
+
+// -----------------------------------------------------------------------------
+
+type journaldConn struct {
+	mu   sync.Mutex
+	addr *net.UnixAddr
+	conn *net.UnixConn
+}
+
+func (c *journaldConn) write(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for attempt := 0; attempt < 2; attempt++ { // one reconnect attempt, journald could be restarted
+		if c.conn == nil {
+			// unconnected socket is used, because file descriptors can't be passed by connected one
+			if c.conn, err = net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"}); err != nil {
+				continue
+			}
+		}
+		if _, err = c.conn.WriteToUnix(data, c.addr); err == nil {
+			return nil
+		}
+		if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+			return c.writeFile(data)
+		}
+		c.conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+// writeFile passes the entry, which is too large for a datagram, as a file descriptor
+// of the sealed memfd or, if memfd is not available, of the unlinked temporary file.
+func (c *journaldConn) writeFile(data []byte) error {
+	f, err := journalDataFile(data)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, _, err = c.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), c.addr)
+	return err //nolint:wrapcheck
+}
+
+func (c *journaldConn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err //nolint:wrapcheck
+}
+
+func journalDataFile(data []byte) (*os.File, error) {
+	if f, err := memfdCreate("mlog-journal"); err == nil {
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return nil, err //nolint:wrapcheck
+		}
+		if err := memfdSeal(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+	dir := "/dev/shm"
+	if _, err := os.Stat(dir); err != nil {
+		dir = os.TempDir()
+	}
+	f, err := os.CreateTemp(dir, "mlog-journal-")
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if err := os.Remove(f.Name()); err != nil { // journald accepts only unlinked regular files
+		f.Close()
+		return nil, err //nolint:wrapcheck
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err //nolint:wrapcheck
+	}
+	return f, nil
+}
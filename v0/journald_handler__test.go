@@ -0,0 +1,132 @@
+//go:build linux
+
+package mlog_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+// journalStandIn receives journal entries like journald does.
+func journalStandIn(t *testing.T) (string, func() map[string]string) {
+	t.Helper()
+	sockPath := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sockPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	receive := func() map[string]string {
+		buf := make([]byte, 1<<20)
+		oob := make([]byte, syscall.CmsgSpace(4))
+		if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := buf[:n]
+		if oobn > 0 { // entry passed as file descriptor
+			msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+			if err != nil {
+				t.Fatal(err)
+			}
+			fds, err := syscall.ParseUnixRights(&msgs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := os.NewFile(uintptr(fds[0]), "journal-entry")
+			defer f.Close()
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			if data, err = io.ReadAll(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return parseJournalEntry(t, data)
+	}
+	return sockPath, receive
+}
+
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	rv := map[string]string{}
+	for len(data) > 0 {
+		eol := bytes.IndexByte(data, '\n')
+		if eol < 0 {
+			t.Fatalf("malformed entry: %q", data)
+		}
+		line := data[:eol]
+		if name, value, ok := bytes.Cut(line, []byte("=")); ok {
+			rv[string(name)] = string(value)
+			data = data[eol+1:]
+			continue
+		}
+		size := binary.LittleEndian.Uint64(data[eol+1:])
+		rv[string(line)] = string(data[eol+9 : eol+9+int(size)])
+		data = data[eol+9+int(size)+1:]
+	}
+	return rv
+}
+
+func Test__JournaldHandler__Fields(t *testing.T) {
+	tt := assert.New(t)
+
+	sockPath, receive := journalStandIn(t)
+	h := mlog.NewJournaldHandler(&mlog.JournaldHandlerOptions{SocketPath: sockPath, AddSource: true, SyslogIdentifier: "app"})
+	defer h.Close()
+	logger := slog.New(h).With("request-id", "r1").WithGroup("db")
+
+	logger.Warn("multi\nline", "query", "select 1", "1st", true)
+	entry := receive()
+	slog.New(h).Error("real", "message", "fake", "priority", 7)
+	forged := receive()
+	tt.EqualValues("real", forged["MESSAGE"])
+	tt.EqualValues("3", forged["PRIORITY"])
+	tt.EqualValues("fake", forged["X_MESSAGE"])
+	tt.EqualValues("7", forged["X_PRIORITY"])
+
+	tt.EqualValues("multi\nline", entry["MESSAGE"])
+	tt.EqualValues("4", entry["PRIORITY"])
+	tt.EqualValues("app", entry["SYSLOG_IDENTIFIER"])
+	tt.EqualValues("r1", entry["REQUEST_ID"])
+	tt.EqualValues("select 1", entry["DB_QUERY"])
+	tt.EqualValues("true", entry["DB_1ST"])
+	tt.True(strings.HasSuffix(entry["CODE_FILE"], "journald_handler__test.go"))
+	tt.True(strings.HasSuffix(entry["CODE_FUNC"], t.Name()))
+	line, err := strconv.Atoi(entry["CODE_LINE"])
+	tt.NoError(err)
+	tt.Positive(line)
+}
+
+func Test__JournaldHandler__LargeEntry(t *testing.T) {
+	tt := assert.New(t)
+
+	sockPath, receive := journalStandIn(t)
+	h := mlog.NewJournaldHandler(&mlog.JournaldHandlerOptions{SocketPath: sockPath, MaxAttrSize: 1 << 20})
+	defer h.Close()
+
+	big := strings.Repeat("x", 512*1024)
+	slog.New(h).Error("large", "payload", big)
+	entry := receive()
+
+	tt.EqualValues("large", entry["MESSAGE"])
+	tt.EqualValues("3", entry["PRIORITY"])
+	tt.EqualValues(big, entry["PAYLOAD"])
+}
@@ -0,0 +1,45 @@
+//go:build linux
+
+package mlog
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	mfdCloexec      = 0x1
+	mfdAllowSealing = 0x2
+	fAddSeals       = 1024 + 9 // F_LINUX_SPECIFIC_BASE + 9
+	fSealSeal       = 0x1
+	fSealShrink     = 0x2
+	fSealGrow       = 0x4
+	fSealWrite      = 0x8
+)
+
+var errMemfdNotSupported = errors.New("memfd_create is not supported on this platform")
+
+func memfdCreate(name string) (*os.File, error) {
+	if sysMemfdCreate == 0 {
+		return nil, errMemfdNotSupported
+	}
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	fd, _, errno := syscall.Syscall(sysMemfdCreate, uintptr(unsafe.Pointer(p)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	return os.NewFile(fd, name), nil
+}
+
+func memfdSeal(f *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), fAddSeals, fSealSeal|fSealShrink|fSealGrow|fSealWrite)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
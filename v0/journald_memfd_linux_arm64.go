@@ -0,0 +1,3 @@
+package mlog
+
+const sysMemfdCreate = 279
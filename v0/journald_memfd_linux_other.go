@@ -0,0 +1,5 @@
+//go:build linux && !amd64 && !arm64
+
+package mlog
+
+const sysMemfdCreate = 0 // unknown, unlinked temporary file is used instead of memfd
@@ -0,0 +1,70 @@
+package mlog
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// lazyConn is a network connection, which is established at the first write
+// and re-established once after the write error, for example if the collector was restarted.
+// It is shared by all handlers, derived by WithAttrs and WithGroup.
+type lazyConn struct {
+	mu   sync.Mutex
+	dial func() (net.Conn, error)
+	conn net.Conn
+}
+
+func newLazyConn(network, addr string, timeout time.Duration) *lazyConn {
+	return &lazyConn{
+		dial: func() (net.Conn, error) {
+			return net.DialTimeout(network, addr, timeout)
+		},
+	}
+}
+
+// write calls fn with the established connection. If fn fails, the connection is closed,
+// re-established and fn is called again.
+func (c *lazyConn) write(fn func(conn net.Conn) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.conn != nil && isStreamConn(c.conn) && isConnClosed(c.conn) {
+			c.conn.Close() // closed by peer, write would succeed, but data would be lost
+			c.conn = nil
+		}
+		if c.conn == nil {
+			if c.conn, err = c.dial(); err != nil {
+				c.conn = nil
+				continue
+			}
+		}
+		if err = fn(c.conn); err == nil {
+			return nil
+		}
+		c.conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+func (c *lazyConn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err //nolint:wrapcheck
+}
+
+// isStreamConn reports whether the connection is stream-oriented, so messages need framing.
+func isStreamConn(conn net.Conn) bool {
+	switch conn.LocalAddr().Network() {
+	case "udp", "udp4", "udp6", "unixgram", "ip", "ip4", "ip6":
+		return false
+	}
+	return true
+}
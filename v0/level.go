@@ -0,0 +1,69 @@
+package mlog
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Levels in addition to the [slog] ones, used by [Logger]. They keep the gaps of [slog] levels,
+// so a level like "NOTICE+1" is still possible. Handlers show their names, see [LevelString].
+const (
+	LevelTrace    = slog.Level(-8)
+	LevelNotice   = slog.Level(2)
+	LevelCritical = slog.Level(12)
+	LevelFatal    = slog.Level(16)
+)
+
+var levelNames = map[slog.Level]string{ //nolint:gochecknoglobals
+	LevelTrace:    "TRACE",
+	LevelNotice:   "NOTICE",
+	LevelCritical: "CRITICAL",
+	LevelFatal:    "FATAL",
+}
+
+// LevelString returns the name of the level like [slog.Level.String] does,
+// but knows the names of [LevelTrace], [LevelNotice], [LevelCritical] and [LevelFatal].
+func LevelString(l slog.Level) string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return l.String()
+}
+
+// ParseLevel parses the level name like [slog.Level.UnmarshalText] does, case-insensitively
+// and with an optional offset, like "warn+2". Names "trace", "notice", "critical" and "fatal"
+// are accepted too.
+func ParseLevel(s string) (slog.Level, error) {
+	name, offset := s, ""
+	if i := strings.IndexAny(s, "+-"); i > 0 {
+		name, offset = s[:i], s[i:]
+	}
+	for l, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			var level slog.Level
+			if err := level.UnmarshalText([]byte(slog.LevelDebug.String() + offset)); err != nil {
+				return 0, fmt.Errorf("unknown level %q: %w", s, err)
+			}
+			return l + level - slog.LevelDebug, nil
+		}
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+	return level, nil
+}
+
+// ReplaceLevelName is the ReplaceAttr function of [slog.HandlerOptions], which writes names
+// of mlog levels, like "TRACE" instead of "DEBUG-4":
+//
+//	slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{ReplaceAttr: mlog.ReplaceLevelName})
+func ReplaceLevelName(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(LevelString(l))
+		}
+	}
+	return a
+}
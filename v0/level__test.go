@@ -0,0 +1,34 @@
+package mlog_test
+
+import (
+	"log/slog"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__ParseLevel(t *testing.T) {
+	tt := assert.New(t)
+
+	for s, level := range map[string]slog.Level{
+		"trace":      mlog.LevelTrace,
+		"TRACE+1":    mlog.LevelTrace + 1,
+		"Notice":     mlog.LevelNotice,
+		"critical-2": mlog.LevelCritical - 2,
+		"fatal":      mlog.LevelFatal,
+		"warn+2":     slog.LevelWarn + 2,
+		"DEBUG":      slog.LevelDebug,
+	} {
+		l, err := mlog.ParseLevel(s)
+		tt.NoError(err, s)
+		tt.EqualValues(level, l, s)
+	}
+	_, err := mlog.ParseLevel("verbose")
+	tt.Error(err)
+	_, err = mlog.ParseLevel("fatal+x")
+	tt.Error(err)
+
+	tt.EqualValues("NOTICE", mlog.LevelString(mlog.LevelNotice))
+	tt.EqualValues("INFO+1", mlog.LevelString(slog.LevelInfo+1))
+}
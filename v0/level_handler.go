@@ -0,0 +1,117 @@
+package mlog
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// LevelHandler is a [slog.Handler] wrapper, which filters records by level and passes them to the next handler.
+// It respects the level override, stored in the context by [WithLevel], so it can be used
+// to make the context-aware level for handlers, which know nothing about it, like [slog.JSONHandler].
+// The override may only lower the level of the handler, and the level of the next handler is bypassed then.
+type LevelHandler struct {
+	level slog.Leveler
+	next  slog.Handler
+}
+
+// NewLevelHandler creates a LevelHandler with the given minimum level.
+// If level is nil, the level of the next handler is used.
+func NewLevelHandler(level slog.Leveler, next slog.Handler) *LevelHandler {
+	return &LevelHandler{
+		level: level,
+		next:  next,
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+// The level override, stored in ctx by [WithLevel], enables lower levels than ones of the handler and the next handler.
+// Implements [slog.Handler] interface.
+func (h *LevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if overrideEnabled(ctx, level) {
+		return true
+	}
+	if h.level != nil && level < h.level.Level() {
+		return false
+	}
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record to the next handler.
+// Implements [slog.Handler] interface.
+func (h *LevelHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	return h.next.Handle(ctx, r) //nolint:wrapcheck
+}
+
+// WithAttrs returns a new LevelHandler whose next handler has given attributes.
+// Implements [slog.Handler] interface.
+func (h *LevelHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return NewLevelHandler(h.level, h.next.WithAttrs(aa))
+}
+
+// WithGroup returns a new LevelHandler whose next handler has the given group.
+// Implements [slog.Handler] interface.
+func (h *LevelHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return NewLevelHandler(h.level, h.next.WithGroup(name))
+}
+
+// Flush flushes the next handler, if it implements [Flusher].
+func (h *LevelHandler) Flush(ctx context.Context) error {
+	return flushNext(ctx, h.next)
+}
+
+// Close closes the next handler, if it implements [Closer].
+func (h *LevelHandler) Close() error {
+	return closeNext(h.next)
+}
+
+// -----------------------------------------------------------------------------
+
+// LevelMiddlewareOptions are options for a [LevelMiddleware].
+type LevelMiddlewareOptions struct {
+	// Header is a name of the request header, which enables the level override.
+	// If empty, [DefaultLevelHeader] is used.
+	Header string
+
+	// Level is used if the header value is not a valid level name, like "debug" or "WARN+2".
+	// If nil, [slog.LevelDebug] is used.
+	Level slog.Leveler
+
+	// Allow is called to check whether the request may override the level, for example
+	// to check credentials or the source address. The header comes from the client, so
+	// if Allow is nil, the header is ignored.
+	Allow func(r *http.Request) bool
+}
+
+// LevelMiddleware returns [http.Handler], which stores the level override (see [WithLevel])
+// into the request context if the request has the configured header and [LevelMiddlewareOptions.Allow]
+// allows it. Logging with the request context in the next handler will use this level, if it is lower
+// than handler's ones.
+// If opts is nil, the default options are used.
+func LevelMiddleware(next http.Handler, opts *LevelMiddlewareOptions) http.Handler {
+	o := LevelMiddlewareOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Header == "" {
+		o.Header = DefaultLevelHeader
+	}
+	if o.Level == nil {
+		o.Level = slog.LevelDebug
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := r.Header.Values(o.Header)
+		if len(values) == 0 || o.Allow == nil || !o.Allow(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		level, err := ParseLevel(values[0])
+		if err != nil {
+			level = o.Level.Level()
+		}
+		next.ServeHTTP(w, r.WithContext(WithLevel(r.Context(), level)))
+	})
+}
@@ -0,0 +1,99 @@
+package mlog_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__LevelHandler__ContextOverride(t *testing.T) {
+	tt := assert.New(t)
+
+	nativeWriter := &bytes.Buffer{}
+	svWriter := &bytes.Buffer{}
+	nativeHandler := mlog.NewLevelHandler(slog.LevelInfo, slog.NewJSONHandler(nativeWriter, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	svHandler := mlog.NewHumanReadableHandler(svWriter, nil)
+	logger := slog.New(mlog.NewMultipleHandler(nil, nativeHandler, svHandler))
+
+	logger.Debug("hidden")
+	logger.DebugContext(mlog.WithLevel(context.Background(), slog.LevelDebug), "shown")
+	logger.InfoContext(mlog.WithLevel(context.Background(), slog.LevelError), "not silenced")
+
+	tt.EqualValues(2, strings.Count(nativeWriter.String(), "\n"))
+	tt.Contains(nativeWriter.String(), "shown")
+	tt.Contains(nativeWriter.String(), "not silenced") // the override can't raise the level
+	tt.EqualValues(2, strings.Count(svWriter.String(), "\n"))
+	tt.Contains(svWriter.String(), "not silenced")
+}
+
+func Test__MtHandler__ChildrenDecide(t *testing.T) {
+	tt := assert.New(t)
+
+	optedWriter := &bytes.Buffer{}
+	plainWriter := &bytes.Buffer{}
+	plainLevel := &slog.LevelVar{}
+	plainLevel.Set(slog.LevelError)
+	logger := slog.New(mlog.NewMultipleHandler(nil,
+		mlog.NewLevelHandler(slog.LevelInfo, slog.NewJSONHandler(optedWriter, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		slog.NewJSONHandler(plainWriter, &slog.HandlerOptions{Level: plainLevel}),
+	))
+
+	logger.DebugContext(mlog.WithLevel(context.Background(), slog.LevelDebug), "override")
+	tt.Contains(optedWriter.String(), "override")
+	tt.Empty(plainWriter.String(), "the handler without override support gets no debug records")
+
+	logger.Warn("warn")
+	tt.NotContains(plainWriter.String(), "warn")
+	plainLevel.Set(slog.LevelWarn)
+	logger.Warn("lowered")
+	tt.Contains(plainWriter.String(), "lowered")
+}
+
+func Test__LevelHandler__Middleware(t *testing.T) {
+	tt := assert.New(t)
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, nil))
+	handler := mlog.LevelMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		logger.DebugContext(r.Context(), "debug "+r.URL.Path)
+		logger.InfoContext(r.Context(), "info "+r.URL.Path)
+	}), &mlog.LevelMiddlewareOptions{
+		Allow: func(r *http.Request) bool { return r.URL.Path != "/denied" },
+	})
+
+	for _, path := range []string{"/plain", "/debug", "/warn", "/denied"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		switch path {
+		case "/debug", "/denied":
+			req.Header.Set(mlog.DefaultLevelHeader, "yes")
+		case "/warn":
+			req.Header.Set(mlog.DefaultLevelHeader, "warn")
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	out := svWriter.String()
+	tt.NotContains(out, "debug /plain")
+	tt.Contains(out, "info /plain")
+	tt.Contains(out, "debug /debug")
+	tt.Contains(out, "info /debug")
+	tt.Contains(out, "info /warn")
+	tt.NotContains(out, "debug /denied")
+	tt.Contains(out, "info /denied")
+
+	svWriter.Reset()
+	handler = mlog.LevelMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		logger.DebugContext(r.Context(), "debug "+r.URL.Path)
+	}), nil)
+	req := httptest.NewRequest(http.MethodGet, "/default", nil)
+	req.Header.Set(mlog.DefaultLevelHeader, "debug")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	tt.Empty(svWriter.String(), "requests are not allowed by default")
+}
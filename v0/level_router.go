@@ -0,0 +1,173 @@
+package mlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LevelRouter is a [slog.Handler] wrapper, which chooses the minimum level by the package of the log call,
+// like RUST_LOG does. The spec is a comma separated list of the default level and package levels:
+//
+//	info,github.com/acme/db=debug,net/http=warn
+//
+// The package is taken from the record's PC (see [DecodeSource]), the longest matching prefix
+// of the import path wins: "github.com/acme/db" matches "github.com/acme/db/pool", but not "github.com/acme/dbx".
+// Levels of packages are cached by PC, so the source is decoded once per log call site.
+//
+// Records, accepted by the router, are passed to the next handler regardless of its own level,
+// so it should log all records it gets, like [slog.JSONHandler] does.
+// The level override, stored in the context by [WithLevel], takes precedence over the spec.
+type LevelRouter struct {
+	spec *atomic.Pointer[levelSpec]
+	next slog.Handler
+}
+
+type levelSpec struct {
+	text  string
+	def   slog.Level
+	min   slog.Level
+	rules []levelRule // sorted by prefix length, the longest first
+	cache sync.Map    // PC -> slog.Level
+}
+
+type levelRule struct {
+	prefix string
+	level  slog.Level
+}
+
+// NewLevelRouter creates a LevelRouter with the given spec. An empty spec means "info" for all packages.
+func NewLevelRouter(spec string, next slog.Handler) (*LevelRouter, error) {
+	s, err := parseLevelSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	h := &LevelRouter{
+		spec: &atomic.Pointer[levelSpec]{},
+		next: next,
+	}
+	h.spec.Store(s)
+	return h, nil
+}
+
+// parseLevelSpec parses the spec, see [LevelRouter].
+func parseLevelSpec(text string) (*levelSpec, error) {
+	s := &levelSpec{text: text, def: slog.LevelInfo}
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		pkg, name, found := strings.Cut(item, "=")
+		if !found {
+			pkg, name = "", item
+		}
+		level, err := ParseLevel(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLevelSpec, item)
+		}
+		pkg = strings.TrimSuffix(strings.TrimSpace(pkg), "/")
+		if pkg == "" {
+			if found {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidLevelSpec, item)
+			}
+			s.def = level
+			continue
+		}
+		s.rules = append(s.rules, levelRule{prefix: pkg, level: level})
+	}
+	sort.SliceStable(s.rules, func(i, j int) bool {
+		return len(s.rules[i].prefix) > len(s.rules[j].prefix)
+	})
+	s.min = s.def
+	for _, rule := range s.rules {
+		s.min = min(s.min, rule.level)
+	}
+	return s, nil
+}
+
+// level returns the minimum level of the log call site.
+func (s *levelSpec) level(pc uintptr) slog.Level {
+	if len(s.rules) == 0 || pc == 0 {
+		return s.def
+	}
+	if l, ok := s.cache.Load(pc); ok {
+		return l.(slog.Level) //nolint:forcetypeassert
+	}
+	level := s.def
+	pkg := functionPackage(DecodeSource(pc).Function)
+	for _, rule := range s.rules {
+		if pkg == rule.prefix || strings.HasPrefix(pkg, rule.prefix+"/") {
+			level = rule.level
+			break
+		}
+	}
+	s.cache.Store(pc, level)
+	return level
+}
+
+// SetSpec replaces the spec of h and all handlers, derived from it.
+func (h *LevelRouter) SetSpec(spec string) error {
+	s, err := parseLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+	h.spec.Store(s)
+	return nil
+}
+
+// Spec returns the current spec.
+func (h *LevelRouter) Spec() string {
+	return h.spec.Load().text
+}
+
+// Enabled reports whether the handler handles records at the given level in some package.
+// The level override, stored in ctx by [WithLevel], enables lower levels than ones of the spec.
+// Implements [slog.Handler] interface.
+func (h *LevelRouter) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.spec.Load().min || overrideEnabled(ctx, level)
+}
+
+// Handle passes the record to the next handler if its level is enabled for the package of the log call.
+// Implements [slog.Handler] interface.
+func (h *LevelRouter) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	if r.Level < h.spec.Load().level(r.PC) && !overrideEnabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r) //nolint:wrapcheck
+}
+
+// WithAttrs returns a new LevelRouter whose next handler has given attributes.
+// Implements [slog.Handler] interface.
+func (h *LevelRouter) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &LevelRouter{
+		spec: h.spec,
+		next: h.next.WithAttrs(aa),
+	}
+}
+
+// WithGroup returns a new LevelRouter whose next handler has the given group.
+// Implements [slog.Handler] interface.
+func (h *LevelRouter) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &LevelRouter{
+		spec: h.spec,
+		next: h.next.WithGroup(name),
+	}
+}
+
+// Flush flushes the next handler, if it implements [Flusher].
+func (h *LevelRouter) Flush(ctx context.Context) error {
+	return flushNext(ctx, h.next)
+}
+
+// Close closes the next handler, if it implements [Closer].
+func (h *LevelRouter) Close() error {
+	return closeNext(h.next)
+}
@@ -0,0 +1,44 @@
+package mlog_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__LevelRouter__Spec(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	router, err := mlog.NewLevelRouter("error, github.com/xenolog=debug, github.com/xenolog/mlog/v0_test=warn",
+		slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	tt.NoError(err)
+	logger := slog.New(router).With("a", 1)
+
+	tt.True(router.Enabled(context.Background(), slog.LevelDebug)) // some package logs debug records
+	logger.Info("hidden by the longest prefix")
+	logger.Warn("shown")
+	tt.EqualValues(1, strings.Count(buf.String(), "\n"))
+	tt.Contains(buf.String(), `"msg":"shown","a":1`)
+
+	tt.NoError(router.SetSpec("warn,github.com/xenolog/mlog=debug"))
+	logger.Debug("shown by the parent package")
+	logger.InfoContext(mlog.WithLevel(context.Background(), slog.LevelError), "not hidden by override")
+	logger.Log(mlog.WithLevel(context.Background(), mlog.LevelTrace), mlog.LevelTrace, "shown by override")
+	tt.EqualValues(4, strings.Count(buf.String(), "\n"))
+	tt.Contains(buf.String(), "shown by the parent package")
+
+	tt.NoError(router.SetSpec("warn,github.com/xenolog/ml=debug"))
+	tt.False(router.Enabled(context.Background(), slog.LevelDebug-1))
+	logger.Info("hidden, prefix is not the package path")
+	tt.EqualValues(4, strings.Count(buf.String(), "\n"))
+
+	tt.ErrorIs(router.SetSpec("info,=debug"), mlog.ErrInvalidLevelSpec)
+	tt.ErrorIs(router.SetSpec("info,net/http=loud"), mlog.ErrInvalidLevelSpec)
+	tt.EqualValues("warn,github.com/xenolog/ml=debug", router.Spec())
+}
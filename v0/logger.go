@@ -0,0 +1,148 @@
+package mlog
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+)
+
+type LoggerOptions struct {
+	// Exit is called by [Logger.Fatal] after handlers are flushed and closed.
+	// If nil, [os.Exit] is used.
+	Exit func(code int)
+	// CloseTimeout limits the time to flush and close handlers by [Logger.Fatal].
+	// If zero, [DefaultCloseTimeout] is used.
+	CloseTimeout time.Duration
+}
+
+// Logger is a [slog.Logger] with additional levels and helpers: Trace, Notice, Critical, Fatal and Panic
+// methods and [Logger.Timed]. Source positions of records point to the caller of these methods,
+// as they do for methods of [slog.Logger].
+type Logger struct {
+	*slog.Logger
+	opts LoggerOptions
+}
+
+// NewLogger creates a Logger, which passes records to the given handler, using the given options.
+// If opts is nil, the default options are used.
+func NewLogger(h slog.Handler, opts *LoggerOptions) *Logger {
+	l := &Logger{Logger: slog.New(h)}
+	if opts != nil {
+		l.opts = *opts
+	}
+	if l.opts.Exit == nil {
+		l.opts.Exit = os.Exit
+	}
+	if l.opts.CloseTimeout <= 0 {
+		l.opts.CloseTimeout = DefaultCloseTimeout
+	}
+	return l
+}
+
+// With returns a Logger that includes the given attributes in each output operation, see [slog.Logger.With].
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{Logger: l.Logger.With(args...), opts: l.opts}
+}
+
+// WithGroup returns a Logger that starts a group, see [slog.Logger.WithGroup].
+func (l *Logger) WithGroup(name string) *Logger {
+	return &Logger{Logger: l.Logger.WithGroup(name), opts: l.opts}
+}
+
+// Trace logs at [LevelTrace].
+func (l *Logger) Trace(msg string, args ...any) {
+	LogSkip(context.Background(), l.Logger, 1, LevelTrace, msg, args...)
+}
+
+// TraceContext logs at [LevelTrace] with the given context.
+func (l *Logger) TraceContext(ctx context.Context, msg string, args ...any) {
+	LogSkip(ctx, l.Logger, 1, LevelTrace, msg, args...)
+}
+
+// Notice logs at [LevelNotice].
+func (l *Logger) Notice(msg string, args ...any) {
+	LogSkip(context.Background(), l.Logger, 1, LevelNotice, msg, args...)
+}
+
+// NoticeContext logs at [LevelNotice] with the given context.
+func (l *Logger) NoticeContext(ctx context.Context, msg string, args ...any) {
+	LogSkip(ctx, l.Logger, 1, LevelNotice, msg, args...)
+}
+
+// Critical logs at [LevelCritical].
+func (l *Logger) Critical(msg string, args ...any) {
+	LogSkip(context.Background(), l.Logger, 1, LevelCritical, msg, args...)
+}
+
+// CriticalContext logs at [LevelCritical] with the given context.
+func (l *Logger) CriticalContext(ctx context.Context, msg string, args ...any) {
+	LogSkip(ctx, l.Logger, 1, LevelCritical, msg, args...)
+}
+
+// Fatal logs at [LevelFatal], flushes and closes handlers like [Shutdown] does, including the handler
+// of the logger, and exits with code 1 by [LoggerOptions.Exit].
+func (l *Logger) Fatal(msg string, args ...any) {
+	LogSkip(context.Background(), l.Logger, 1, LevelFatal, msg, args...)
+	l.exit()
+}
+
+// FatalContext logs at [LevelFatal] with the given context, then exits like [Logger.Fatal] does.
+func (l *Logger) FatalContext(ctx context.Context, msg string, args ...any) {
+	LogSkip(ctx, l.Logger, 1, LevelFatal, msg, args...)
+	l.exit()
+}
+
+// Panic logs at [LevelCritical], then panics with the message.
+func (l *Logger) Panic(msg string, args ...any) {
+	LogSkip(context.Background(), l.Logger, 1, LevelCritical, msg, args...)
+	panic(msg)
+}
+
+// PanicContext logs at [LevelCritical] with the given context, then panics with the message.
+func (l *Logger) PanicContext(ctx context.Context, msg string, args ...any) {
+	LogSkip(ctx, l.Logger, 1, LevelCritical, msg, args...)
+	panic(msg)
+}
+
+// Timed returns the function, which logs the operation with its elapsed time in [DurationKey] attribute
+// and the error in [ErrorKey] attribute. The record has [slog.LevelInfo] level, or [slog.LevelError]
+// if err is not nil, and the source position of the Timed call:
+//
+//	done := logger.Timed(ctx, "load config", "path", path)
+//	err := load(path)
+//	done(err)
+func (l *Logger) Timed(ctx context.Context, op string, args ...any) func(err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:]) //nolint:gomnd // skip runtime.Callers() and Timed()
+	start := time.Now()
+	return func(err error) {
+		level := slog.LevelInfo
+		if err != nil {
+			level = slog.LevelError
+		}
+		if !l.Enabled(ctx, level) {
+			return
+		}
+		r := slog.NewRecord(time.Now(), level, op, pcs[0])
+		r.Add(args...)
+		r.AddAttrs(slog.Duration(DurationKey, time.Since(start)))
+		if err != nil {
+			r.AddAttrs(slog.Any(ErrorKey, err))
+		}
+		_ = l.Handler().Handle(ctx, r)
+	}
+}
+
+// exit flushes and closes the handler of the logger, the handler of [slog.Default] and handlers,
+// registered by [OnShutdown], then calls the exit function.
+func (l *Logger) exit() {
+	ctx, cancel := context.WithTimeout(context.Background(), l.opts.CloseTimeout)
+	defer cancel()
+	_ = shutdown(ctx, l.Handler(), slog.Default().Handler())
+	l.opts.Exit(1)
+}
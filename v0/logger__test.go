@@ -0,0 +1,79 @@
+package mlog_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+// closableHandler records Flush and Close calls.
+type closableHandler struct {
+	slog.Handler
+	calls []string
+}
+
+func (h *closableHandler) Flush(context.Context) error {
+	h.calls = append(h.calls, "flush")
+	return nil
+}
+
+func (h *closableHandler) Close() error {
+	h.calls = append(h.calls, "close")
+	return nil
+}
+
+func Test__Logger__Levels(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	h := &closableHandler{Handler: mlog.NewHumanReadableHandler(buf, &mlog.HumanReadableHandlerOptions{
+		AddSource: true,
+		Level:     mlog.LevelTrace,
+	})}
+	exitCode := -1
+	logger := mlog.NewLogger(h, &mlog.LoggerOptions{Exit: func(code int) { exitCode = code }})
+
+	logger.Trace("trace")
+	logger.With("a", 1).Notice("notice")
+	logger.Critical("critical")
+	tt.PanicsWithValue("panic", func() { logger.Panic("panic") })
+	tt.Empty(h.calls)
+	logger.Fatal("fatal")
+	tt.EqualValues([]string{"flush", "close"}, h.calls)
+	tt.EqualValues(1, exitCode)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	tt.Len(lines, 5)
+	for i, prefix := range []string{" T [", " N [", " C [", " C [", " F ["} {
+		tt.Contains(lines[i], prefix+"logger__test.go:", lines[i])
+	}
+	tt.Contains(lines[1], `notice  ATTRS={"a":1}`)
+}
+
+func Test__Logger__Timed(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	logger := mlog.NewLogger(slog.NewJSONHandler(buf, &slog.HandlerOptions{
+		AddSource:   true,
+		ReplaceAttr: mlog.ReplaceLevelName,
+	}), nil)
+
+	done := logger.Timed(context.Background(), "load", "path", "/etc")
+	done(nil)
+	logger.Timed(nil, "save")(errors.New("failed")) //nolint:staticcheck
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	tt.Len(lines, 2)
+	tt.Contains(lines[0], `"level":"INFO"`)
+	tt.Contains(lines[0], `"msg":"load","path":"/etc","duration":`)
+	tt.Contains(lines[0], `logger__test.go"`)
+	tt.Contains(lines[1], `"level":"ERROR"`)
+	tt.Contains(lines[1], `"error":"failed"`)
+}
@@ -0,0 +1,411 @@
+package mlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LokiFormat is a format of the log lines, pushed to Loki.
+type LokiFormat int
+
+const (
+	// LokiFormatHumanReadable formats lines as [HumanReadableHandler] does.
+	LokiFormatHumanReadable LokiFormat = iota
+	// LokiFormatJSON formats lines as [slog.JSONHandler] does, without the time field.
+	LokiFormatJSON
+)
+
+// LokiEncoding is an encoding of the push requests.
+type LokiEncoding int
+
+const (
+	// LokiEncodingProtobuf encodes requests as snappy-compressed protobuf.
+	LokiEncodingProtobuf LokiEncoding = iota
+	// LokiEncodingJSON encodes requests as JSON, optionally gzip-compressed.
+	LokiEncodingJSON
+)
+
+type LokiHandlerOptions struct {
+	// Endpoint is the Loki URL, like "http://localhost:3100". If the URL has no path, "/loki/api/v1/push" is used.
+	// If empty, [DefaultLokiEndpoint] is used.
+	Endpoint string
+	// Encoding is the request encoding, snappy-compressed protobuf by default.
+	Encoding LokiEncoding
+	// Gzip enables compression of JSON requests.
+	Gzip bool
+	// TenantID is sent as "X-Scope-OrgID" header in the multi-tenant Loki.
+	TenantID string
+	// Headers are added to each request, for example to pass the authorization token.
+	Headers map[string]string
+	// Client is used to send requests. If nil, the client with [DefaultHTTPTimeout] is used.
+	Client *http.Client
+
+	// Labels are static labels of all streams, like {"job": "api"}. The "level" label is always added.
+	Labels map[string]string
+	// LabelAttrs are keys of attributes, which are sent as labels instead of the line content.
+	// Keys of attributes in groups are joined by dot, like "http.method"; the label name
+	// is the key with invalid characters replaced by underscore.
+	LabelAttrs []string
+	// MaxLabelValues limits the number of distinct values of each label, taken from attributes,
+	// to keep the number of streams bounded. Extra values are replaced by "_overflow_".
+	// If zero, [DefaultMaxLabelValues] is used.
+	MaxLabelValues int
+
+	// Format of the log lines, [HumanReadableHandler] format by default.
+	Format LokiFormat
+	// LineOptions configures the line format, used with [LokiFormatHumanReadable].
+	// Level of these options is ignored.
+	LineOptions *HumanReadableHandlerOptions
+	// AddSource adds the source code position to JSON lines, see [slog.HandlerOptions].
+	AddSource bool
+
+	// Batch configures batching, backpressure and retries of the requests.
+	Batch BatchOptions
+
+	// MaxAttrDepth and MaxAttrSize limit values of label attributes, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to log.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelInfo].
+	Level slog.Leveler
+}
+
+// LokiHandler is a [slog.Handler] that pushes Records to Grafana Loki.
+// Records are grouped into streams by labels, formatted as lines by [HumanReadableHandler] or [slog.JSONHandler],
+// queued and pushed in batches by the background goroutine, so Handle never blocks on the network
+// (unless [BatchOptions.BlockTimeout] is set).
+type LokiHandler struct {
+	opts    LokiHandlerOptions
+	enc     valueEncoder
+	labels  map[string]string // static labels and labels, taken from WithAttrs
+	prefix  string            // groups, joined by dot
+	inner   slog.Handler      // renders the line into line buffer
+	line    *lineBuffer
+	limiter *labelLimiter
+	batcher *batcher[lokiEntry]
+}
+
+type lokiEntry struct {
+	labels map[string]string
+	stream string // labels in the Loki text form, identifies the stream
+	time   time.Time
+	line   string
+}
+
+// lineBuffer captures the output of the inner handler. mu is held while the line is rendered and taken.
+type lineBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *lineBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// labelLimiter keeps distinct values of the labels to limit their number.
+type labelLimiter struct {
+	mu     sync.Mutex
+	max    int
+	values map[string]map[string]struct{}
+}
+
+func (l *labelLimiter) value(name, value string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seen := l.values[name]
+	if seen == nil {
+		seen = map[string]struct{}{}
+		l.values[name] = seen
+	}
+	if _, ok := seen[value]; ok {
+		return value
+	}
+	if len(seen) >= l.max {
+		return lokiOverflowValue
+	}
+	seen[value] = struct{}{}
+	return value
+}
+
+// NewLokiHandler creates a LokiHandler, using the given options, and starts the background sending goroutine.
+func NewLokiHandler(opts *LokiHandlerOptions) *LokiHandler {
+	h := &LokiHandler{
+		line: &lineBuffer{},
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	if h.opts.Endpoint == "" {
+		h.opts.Endpoint = DefaultLokiEndpoint
+	}
+	if h.opts.Client == nil {
+		h.opts.Client = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+	if h.opts.MaxLabelValues <= 0 {
+		h.opts.MaxLabelValues = DefaultMaxLabelValues
+	}
+	h.enc = newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize)
+	h.labels = map[string]string{}
+	for k, v := range h.opts.Labels {
+		h.labels[lokiLabelName(k)] = v
+	}
+	h.limiter = &labelLimiter{max: h.opts.MaxLabelValues, values: map[string]map[string]struct{}{}}
+
+	allLevels := slog.Level(math.MinInt)
+	if h.opts.Format == LokiFormatJSON {
+		h.inner = slog.NewJSONHandler(h.line, &slog.HandlerOptions{
+			AddSource: h.opts.AddSource,
+			Level:     allLevels,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && a.Key == slog.TimeKey {
+					return slog.Attr{} // Loki keeps the entry timestamp
+				}
+				return a
+			},
+		})
+	} else {
+		lineOpts := HumanReadableHandlerOptions{}
+		if h.opts.LineOptions != nil {
+			lineOpts = *h.opts.LineOptions
+		}
+		lineOpts.Level = allLevels
+		h.inner = NewHumanReadableHandler(h.line, &lineOpts)
+	}
+
+	endpoint := h.opts.Endpoint
+	if u, err := url.Parse(endpoint); err == nil && strings.Trim(u.Path, "/") == "" {
+		u.Path = lokiPushPath
+		endpoint = u.String()
+	}
+	headers := maps.Clone(h.opts.Headers)
+	if h.opts.TenantID != "" {
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers["X-Scope-OrgID"] = h.opts.TenantID
+	}
+	sender := httpSender{client: h.opts.Client, url: endpoint, headers: headers}
+	if h.opts.Encoding == LokiEncodingJSON {
+		sender.gzip = h.opts.Gzip
+	}
+	h.batcher = newBatcher(h.opts.Batch, func(ctx context.Context, batch []lokiEntry) error {
+		if h.opts.Encoding == LokiEncodingJSON {
+			body, err := json.Marshal(lokiJSONRequest(batch))
+			if err != nil {
+				return permanentError{err}
+			}
+			_, err = sender.post(ctx, "application/json", body)
+			return err
+		}
+		_, err := sender.post(ctx, "application/x-protobuf", snappyEncode(lokiProtoRequest(batch)))
+		return err
+	})
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *LokiHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle extracts labels from the Record, formats the rest of it as line and queues the entry.
+// [ErrBufferFull] is returned if the queue is full.
+// Implements [slog.Handler] interface.
+func (h *LokiHandler) Handle(ctx context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
+	entry := lokiEntry{
+		labels: maps.Clone(h.labels),
+		time:   r.Time,
+	}
+	if entry.time.IsZero() {
+		entry.time = time.Now()
+	}
+	entry.labels[lokiLevelLabel] = strings.ToLower(LevelString(r.Level))
+
+	rr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if !h.addLabel(entry.labels, a) {
+			rr.AddAttrs(a)
+		}
+		return true
+	})
+	entry.stream = lokiStream(entry.labels)
+
+	h.line.mu.Lock()
+	h.line.buf = h.line.buf[:0]
+	err = h.inner.Handle(ctx, rr)
+	entry.line = strings.TrimSuffix(string(h.line.buf), "\n")
+	h.line.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := h.batcher.add(entry); err != nil {
+		return errors.Join(Error, err)
+	}
+	return nil
+}
+
+// addLabel stores the attribute into labels, if it is configured as label attribute.
+func (h *LokiHandler) addLabel(labels map[string]string, a slog.Attr) bool {
+	key := h.prefix + a.Key
+	for _, k := range h.opts.LabelAttrs {
+		if k == key {
+			name := lokiLabelName(key)
+			labels[name] = h.limiter.value(name, formatScalar(h.enc.value(h.enc.resolve(a.Value), 0)))
+			return true
+		}
+	}
+	return false
+}
+
+// WithAttrs returns a new LokiHandler whose attributes consists of h's attributes followed by attrs.
+// Implements [slog.Handler] interface.
+func (h *LokiHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	hh := *h
+	hh.labels = maps.Clone(h.labels)
+	rest := make([]slog.Attr, 0, len(aa))
+	for _, a := range aa {
+		if !hh.addLabel(hh.labels, a) {
+			rest = append(rest, a)
+		}
+	}
+	hh.inner = h.inner.WithAttrs(rest)
+	return &hh
+}
+
+// WithGroup returns a new LokiHandler with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *LokiHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	hh := *h
+	hh.prefix = h.prefix + name + "."
+	hh.inner = h.inner.WithGroup(name)
+	return &hh
+}
+
+// Flush sends all queued records and returns the error of the last failed request.
+func (h *LokiHandler) Flush(ctx context.Context) error {
+	return h.batcher.flush(ctx)
+}
+
+// Close sends queued records during [BatchOptions.CloseTimeout] and stops the background goroutine.
+// The queue is shared by all handlers, derived by WithAttrs and WithGroup.
+func (h *LokiHandler) Close() error {
+	return h.batcher.close()
+}
+
+// Dropped returns the number of records, which were dropped because of queue overflow or failed requests.
+func (h *LokiHandler) Dropped() uint64 {
+	return h.batcher.dropped.Load()
+}
+
+// -----------------------------------------------------------------------------
+
+var lokiInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]`) //nolint:gochecknoglobals
+
+// lokiLabelName returns label name, which matches [a-zA-Z_][a-zA-Z0-9_]*.
+func lokiLabelName(key string) string {
+	name := lokiInvalidChars.ReplaceAllString(key, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// lokiStream returns labels in the Loki text form, like {job="api", level="info"}.
+func lokiStream(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf := []byte{'{'}
+	for i, k := range keys {
+		if i != 0 {
+			buf = append(buf, ", "...)
+		}
+		buf = append(buf, k...)
+		buf = append(buf, '=')
+		buf = strconv.AppendQuote(buf, labels[k])
+	}
+	return string(append(buf, '}'))
+}
+
+// lokiStreams groups entries by streams, keeping the order of entries.
+func lokiStreams(batch []lokiEntry) [][]*lokiEntry {
+	rv := [][]*lokiEntry{}
+	index := map[string]int{}
+	for i := range batch {
+		idx, ok := index[batch[i].stream]
+		if !ok {
+			idx = len(rv)
+			index[batch[i].stream] = idx
+			rv = append(rv, nil)
+		}
+		rv[idx] = append(rv[idx], &batch[i])
+	}
+	return rv
+}
+
+func lokiJSONRequest(batch []lokiEntry) map[string]any {
+	streams := []any{}
+	for _, entries := range lokiStreams(batch) {
+		values := make([]any, 0, len(entries))
+		for _, e := range entries {
+			values = append(values, []string{strconv.FormatInt(e.time.UnixNano(), 10), e.line})
+		}
+		streams = append(streams, map[string]any{
+			"stream": entries[0].labels,
+			"values": values,
+		})
+	}
+	return map[string]any{"streams": streams}
+}
+
+// lokiProtoRequest encodes logproto.PushRequest.
+func lokiProtoRequest(batch []lokiEntry) []byte {
+	var b []byte
+	for _, entries := range lokiStreams(batch) {
+		b = protoAppendMessage(b, 1, func(b []byte) []byte { // StreamAdapter streams = 1
+			b = protoAppendString(b, 1, entries[0].stream)
+			for _, e := range entries {
+				b = protoAppendMessage(b, 2, func(b []byte) []byte { // EntryAdapter entries = 2
+					b = protoAppendMessage(b, 1, func(b []byte) []byte { // google.protobuf.Timestamp timestamp = 1
+						b = protoAppendVarint(b, 1, uint64(e.time.Unix()))
+						return protoAppendVarint(b, 2, uint64(e.time.Nanosecond()))
+					})
+					return protoAppendString(b, 2, e.line)
+				})
+			}
+			return b
+		})
+	}
+	return b
+}
@@ -0,0 +1,153 @@
+package mlog_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+// snappyDecode decodes snappy block format.
+func snappyDecode(t *testing.T, src []byte) []byte {
+	t.Helper()
+	size, n := binary.Uvarint(src)
+	assert.Greater(t, n, 0)
+	src = src[n:]
+	dst := make([]byte, 0, size)
+	for len(src) != 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case 0: // literal
+			l := int(tag >> 2)
+			src = src[1:]
+			if l >= 60 {
+				extra := l - 59
+				l = 0
+				for i := extra - 1; i >= 0; i-- {
+					l = l<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			dst = append(dst, src[:l+1]...)
+			src = src[l+1:]
+		case 2: // copy with 2-byte offset
+			l := int(tag>>2) + 1
+			offset := int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+			for i := 0; i < l; i++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		default:
+			t.Fatalf("unexpected snappy tag %d", tag&3)
+		}
+	}
+	assert.Len(t, dst, int(size))
+	return dst
+}
+
+func Test__LokiHandler__JSON_Labels(t *testing.T) {
+	tt := assert.New(t)
+
+	c := newCollector(t)
+	h := mlog.NewLokiHandler(&mlog.LokiHandlerOptions{
+		Endpoint:       c.server.URL,
+		Encoding:       mlog.LokiEncodingJSON,
+		Gzip:           true,
+		TenantID:       "team-a",
+		Labels:         map[string]string{"job": "api"},
+		LabelAttrs:     []string{"user", "http.method"},
+		MaxLabelValues: 2,
+		Format:         mlog.LokiFormatJSON,
+	})
+	defer h.Close()
+	logger := slog.New(h)
+	for _, user := range []string{"u1", "u2", "u3"} {
+		logger.With("user", user).WithGroup("http").Warn("request", "method", "GET", "path", "/x")
+	}
+	tt.NoError(h.Flush(flushCtx(t)))
+
+	requests, bodies := c.received()
+	tt.Len(requests, 1)
+	tt.EqualValues("/loki/api/v1/push", requests[0].URL.Path)
+	tt.EqualValues("team-a", requests[0].Header.Get("X-Scope-OrgID"))
+	tt.EqualValues("gzip", requests[0].Header.Get("Content-Encoding"))
+	zr, err := gzip.NewReader(bytes.NewReader(bodies[0]))
+	tt.NoError(err)
+	body, err := io.ReadAll(zr)
+	tt.NoError(err)
+
+	data := map[string]any{}
+	tt.NoError(json.Unmarshal(body, &data))
+	streams := JqMust(t, data, ".streams").([]any)
+	tt.Len(streams, 3)
+	for i, user := range []string{"u1", "u2", "_overflow_"} {
+		tt.EqualValues(
+			map[string]any{"job": "api", "level": "warn", "user": user, "http_method": "GET"},
+			JqMust(t, streams[i], ".stream"))
+		line := JqMust(t, streams[i], ".values[0][1]").(string)
+		lineData := map[string]any{}
+		tt.NoError(json.Unmarshal([]byte(line), &lineData))
+		tt.EqualValues(map[string]any{"level": "WARN", "msg": "request", "http": map[string]any{"path": "/x"}}, lineData)
+	}
+}
+
+func Test__LokiHandler__Protobuf(t *testing.T) {
+	tt := assert.New(t)
+
+	c := newCollector(t)
+	h := mlog.NewLokiHandler(&mlog.LokiHandlerOptions{
+		Endpoint: c.server.URL,
+		Labels:   map[string]string{"job": "api"},
+	})
+	defer h.Close()
+	logger := slog.New(h)
+	logger.Info("first line", "k", strings.Repeat("abcd", 50))
+	logger.Error("second line")
+	tt.NoError(h.Flush(flushCtx(t)))
+
+	requests, bodies := c.received()
+	tt.Len(requests, 1)
+	tt.EqualValues("application/x-protobuf", requests[0].Header.Get("Content-Type"))
+	tt.Less(len(bodies[0]), 200) // repeated value is compressed
+	body := snappyDecode(t, bodies[0])
+	tt.True(bytes.Contains(body, []byte(`{job="api", level="info"}`)))
+	tt.True(bytes.Contains(body, []byte(`{job="api", level="error"}`)))
+	tt.True(bytes.Contains(body, []byte(" I --  first line  ATTRS={\"k\":\""+strings.Repeat("abcd", 50)+"\"}")))
+	tt.True(bytes.Contains(body, []byte(" E --  second line")))
+}
+
+func Test__LokiHandler__Backpressure(t *testing.T) {
+	tt := assert.New(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // collector is overloaded
+	}))
+	defer server.Close()
+	h := mlog.NewLokiHandler(&mlog.LokiHandlerOptions{
+		Endpoint: server.URL,
+		Batch:    mlog.BatchOptions{QueueSize: 1, MaxBatchSize: 1, BlockTimeout: 10 * time.Millisecond},
+	})
+	defer h.Close()
+	logger := slog.New(h)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		logger.Info("message")
+	}
+	tt.GreaterOrEqual(time.Since(start), 10*time.Millisecond) // Handle waited for the free space
+	tt.Greater(h.Dropped(), uint64(0))
+
+	close(release)
+	tt.NoError(h.Flush(flushCtx(t)))
+}
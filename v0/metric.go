@@ -0,0 +1,25 @@
+package mlog
+
+import (
+	"log/slog"
+)
+
+// MetricValue is a value of the metric attribute, created by [Metric].
+// Handlers, which don't support metrics, render it as the plain number.
+type MetricValue struct {
+	Value float64
+	Unit  string
+}
+
+// LogValue returns the metric value as number.
+// Implements [slog.LogValuer] interface.
+func (m MetricValue) LogValue() slog.Value {
+	return slog.Float64Value(m.Value)
+}
+
+// Metric returns an attribute, which tags the record as a metric. Unit is a CloudWatch unit,
+// like "Count", "Milliseconds" or "Bytes", it may be empty. [CloudHandler] with [CloudProfileAWS]
+// sends such records as CloudWatch Embedded Metric Format.
+func Metric(name string, value float64, unit string) slog.Attr {
+	return slog.Any(name, MetricValue{Value: value, Unit: unit})
+}
@@ -0,0 +1,346 @@
+package mlog
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type MetricsHandlerOptions struct {
+	// Namespace is the prefix of metric names, like "mlog_records_total". If empty, [DefaultMetricNamespace] is used.
+	Namespace string
+	// LabelAttrs are keys of attributes, which are used as labels of the records counter.
+	// Keys of attributes in groups are joined by dot, like "http.method"; the label name
+	// is the key with invalid characters replaced by underscore.
+	LabelAttrs []string
+	// MaxLabelValues limits the number of distinct values of each label, taken from attributes,
+	// to keep the number of series bounded. Extra values are replaced by "_overflow_".
+	// If zero, [DefaultMaxLabelValues] is used.
+	MaxLabelValues int
+
+	// MaxAttrDepth and MaxAttrSize limit values of label attributes, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to count.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelDebug].
+	Level slog.Leveler
+}
+
+// MetricsHandler is a [slog.Handler] that counts Records by level and label attributes,
+// and collects statistics of other handlers: records and errors of handlers, wrapped by [MetricsHandler.Wrap],
+// drops and write errors of handlers and writers, registered by [MetricsHandler.Watch].
+// Metrics are served in Prometheus text exposition format (see [MetricsHandler.ServeHTTP])
+// and can be published by [expvar] (see [MetricsHandler.Publish]).
+// It is intended to be one leg of [MultipleHandler]:
+//
+//	metrics := mlog.NewMetricsHandler(nil)
+//	logger := slog.New(mlog.NewMultipleHandler(nil, metrics, metrics.Wrap("loki", loki)))
+//	http.Handle("/metrics", metrics)
+type MetricsHandler struct {
+	opts    MetricsHandlerOptions
+	enc     valueEncoder
+	labels  map[string]string // labels, taken from WithAttrs
+	prefix  string            // groups, joined by dot
+	limiter *labelLimiter
+	stats   *metricsStats
+}
+
+type metricsStats struct {
+	mu       sync.RWMutex
+	records  map[string]*atomic.Uint64 // by labels in the Prometheus text form
+	handlers map[string]*handlerStats
+}
+
+// handlerStats are statistics of the wrapped or watched handler.
+type handlerStats struct {
+	records atomic.Uint64
+	errors  atomic.Uint64
+	wrapped bool
+	source  any // Dropped() or Errors() are called on scrape, if implemented
+}
+
+// NewMetricsHandler creates a MetricsHandler, using the given options.
+// If opts is nil, the default options are used.
+func NewMetricsHandler(opts *MetricsHandlerOptions) *MetricsHandler {
+	h := &MetricsHandler{}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelDebug
+	}
+	if h.opts.Namespace == "" {
+		h.opts.Namespace = DefaultMetricNamespace
+	}
+	if h.opts.MaxLabelValues <= 0 {
+		h.opts.MaxLabelValues = DefaultMaxLabelValues
+	}
+	h.enc = newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize)
+	h.labels = map[string]string{}
+	h.limiter = &labelLimiter{max: h.opts.MaxLabelValues, values: map[string]map[string]struct{}{}}
+	h.stats = &metricsStats{
+		records:  map[string]*atomic.Uint64{},
+		handlers: map[string]*handlerStats{},
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *MetricsHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle counts the Record.
+// Implements [slog.Handler] interface.
+func (h *MetricsHandler) Handle(_ context.Context, r slog.Record) error { //nolint:gocritic
+	labels := h.labels
+	if len(h.opts.LabelAttrs) != 0 && r.NumAttrs() != 0 {
+		labels = maps.Clone(h.labels)
+		r.Attrs(func(a slog.Attr) bool {
+			h.addLabel(labels, h.prefix, a)
+			return true
+		})
+	}
+	key := promLabels(labels, "level", strings.ToLower(LevelString(r.Level)))
+	h.stats.counter(key).Add(1)
+	return nil
+}
+
+// addLabel stores the attribute into labels, if it is configured as label attribute.
+// Attributes of groups are added with keys, joined by dot to the prefix.
+func (h *MetricsHandler) addLabel(labels map[string]string, prefix string, a slog.Attr) {
+	v := h.enc.resolve(a.Value)
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" { // the group with empty key is inlined
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			h.addLabel(labels, prefix, ga)
+		}
+		return
+	}
+	key := prefix + a.Key
+	for _, k := range h.opts.LabelAttrs {
+		if k == key {
+			name := lokiLabelName(key)
+			labels[name] = h.limiter.value(name, formatScalar(h.enc.value(v, 0)))
+			return
+		}
+	}
+}
+
+func (s *metricsStats) counter(key string) *atomic.Uint64 {
+	s.mu.RLock()
+	c, ok := s.records[key]
+	s.mu.RUnlock()
+	if ok {
+		return c
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok = s.records[key]; !ok {
+		c = &atomic.Uint64{}
+		s.records[key] = c
+	}
+	return c
+}
+
+// WithAttrs returns a new MetricsHandler, which takes label attributes from attrs too.
+// Implements [slog.Handler] interface.
+func (h *MetricsHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	hh := *h
+	hh.labels = maps.Clone(h.labels)
+	for _, a := range aa {
+		hh.addLabel(hh.labels, h.prefix, a)
+	}
+	return &hh
+}
+
+// WithGroup returns a new MetricsHandler with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *MetricsHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	hh := *h
+	hh.prefix = h.prefix + name + "."
+	return &hh
+}
+
+// Wrap returns the handler, which passes records to next and counts them and returned errors
+// with the "handler" label. Drops and write errors of next are reported too, see [MetricsHandler.Watch].
+func (h *MetricsHandler) Wrap(name string, next slog.Handler) slog.Handler {
+	stats := h.watch(name, next)
+	stats.wrapped = true
+	return &metricsWrapper{stats: stats, next: next}
+}
+
+// Watch reports drops and write errors of the handler or writer with the "handler" label,
+// if it has Dropped() uint64 or Errors() uint64 methods, like [OTLPHandler] or [NetWriter] do.
+func (h *MetricsHandler) Watch(name string, source any) {
+	h.watch(name, source)
+}
+
+func (h *MetricsHandler) watch(name string, source any) *handlerStats {
+	stats := &handlerStats{source: source}
+	h.stats.mu.Lock()
+	defer h.stats.mu.Unlock()
+	h.stats.handlers[name] = stats
+	return stats
+}
+
+// metricsFamily is one metric with series in the Prometheus text form.
+type metricsFamily struct {
+	name, help string
+	series     map[string]uint64
+}
+
+// families returns all metrics, sorted by name.
+func (h *MetricsHandler) families() []metricsFamily {
+	ns := h.opts.Namespace + "_"
+	records := metricsFamily{ns + "records_total", "Number of log records by level and attributes.", map[string]uint64{}}
+	handled := metricsFamily{ns + "handler_records_total", "Number of log records, passed to the handler.", map[string]uint64{}}
+	errs := metricsFamily{ns + "handler_errors_total", "Number of errors, returned by the handler.", map[string]uint64{}}
+	dropped := metricsFamily{ns + "handler_dropped_total", "Number of log records, dropped by the handler.", map[string]uint64{}}
+	writeErrs := metricsFamily{ns + "handler_write_errors_total", "Number of failed writes of the handler.", map[string]uint64{}}
+
+	h.stats.mu.RLock()
+	defer h.stats.mu.RUnlock()
+	for key, c := range h.stats.records {
+		records.series[key] = c.Load()
+	}
+	for name, stats := range h.stats.handlers {
+		key := promLabels(nil, "handler", name)
+		if stats.wrapped {
+			handled.series[key] = stats.records.Load()
+			errs.series[key] = stats.errors.Load()
+		}
+		if d, ok := stats.source.(interface{ Dropped() uint64 }); ok {
+			dropped.series[key] = d.Dropped()
+		}
+		if e, ok := stats.source.(interface{ Errors() uint64 }); ok {
+			writeErrs.series[key] = e.Errors()
+		}
+	}
+	return []metricsFamily{dropped, errs, handled, writeErrs, records}
+}
+
+// WriteTo writes metrics in Prometheus text exposition format.
+// Implements [io.WriterTo] interface.
+func (h *MetricsHandler) WriteTo(w io.Writer) (int64, error) {
+	var buf strings.Builder
+	for _, f := range h.families() {
+		if len(f.series) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s counter\n", f.name, f.help, f.name)
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&buf, "%s{%s} %d\n", f.name, k, f.series[k])
+		}
+	}
+	n, err := io.WriteString(w, buf.String())
+	return int64(n), err //nolint:wrapcheck
+}
+
+// ServeHTTP serves metrics in Prometheus text exposition format.
+// Implements [http.Handler] interface.
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.WriteTo(w) //nolint:errcheck
+}
+
+// Publish publishes metrics as [expvar] variable with the given name, i.e. they are served by "/debug/vars"
+// as the object {"metric_name": {"label=\"value\"": count}}. Like [expvar.Publish], it panics if the name is already used.
+func (h *MetricsHandler) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		rv := map[string]map[string]uint64{}
+		for _, f := range h.families() {
+			if len(f.series) != 0 {
+				rv[f.name] = f.series
+			}
+		}
+		return rv
+	}))
+}
+
+// -----------------------------------------------------------------------------
+
+// metricsWrapper counts records and errors of the next handler.
+type metricsWrapper struct {
+	stats *handlerStats
+	next  slog.Handler
+}
+
+func (h *metricsWrapper) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *metricsWrapper) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	h.stats.records.Add(1)
+	if err := h.next.Handle(ctx, r); err != nil {
+		h.stats.errors.Add(1)
+		return err //nolint:wrapcheck
+	}
+	return nil
+}
+
+func (h *metricsWrapper) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &metricsWrapper{stats: h.stats, next: h.next.WithAttrs(aa)}
+}
+
+func (h *metricsWrapper) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &metricsWrapper{stats: h.stats, next: h.next.WithGroup(name)}
+}
+
+func (h *metricsWrapper) Flush(ctx context.Context) error {
+	return flushNext(ctx, h.next)
+}
+
+func (h *metricsWrapper) Close() error {
+	return closeNext(h.next)
+}
+
+var promLabelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`) //nolint:gochecknoglobals
+
+// promLabels returns labels and the extra label in the Prometheus text form, like `job="api",level="info"`.
+func promLabels(labels map[string]string, name, value string) string {
+	keys := make([]string, 0, len(labels)+1)
+	for k := range labels {
+		if k != name {
+			keys = append(keys, k)
+		}
+	}
+	keys = append(keys, name)
+	sort.Strings(keys)
+	var buf strings.Builder
+	for i, k := range keys {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		v := value
+		if k != name {
+			v = labels[k]
+		}
+		buf.WriteString(k + `="` + promLabelValueReplacer.Replace(v) + `"`)
+	}
+	return buf.String()
+}
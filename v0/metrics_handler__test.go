@@ -0,0 +1,91 @@
+package mlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+// failingHandler returns an error for records with "fail" message.
+type failingHandler struct {
+	slog.Handler
+}
+
+func (h failingHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	if r.Message == "fail" {
+		return errors.New("failed")
+	}
+	return h.Handler.Handle(ctx, r) //nolint:wrapcheck
+}
+
+type droppingWriter struct{}
+
+func (droppingWriter) Dropped() uint64 { return 7 }
+func (droppingWriter) Errors() uint64  { return 2 }
+
+func Test__MetricsHandler__Prometheus(t *testing.T) {
+	tt := assert.New(t)
+
+	metrics := mlog.NewMetricsHandler(&mlog.MetricsHandlerOptions{
+		LabelAttrs:     []string{"component", "http.status"},
+		MaxLabelValues: 2,
+	})
+	next := failingHandler{slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug})}
+	logger := slog.New(mlog.NewMultipleHandler(nil, metrics, metrics.Wrap("json", next)))
+	metrics.Watch("net", droppingWriter{})
+
+	logger.Debug("debug")
+	logger.Info("info", "component", "db")
+	db := logger.With("component", "db")
+	db.Info("info")
+	db.WithGroup("http").Warn("request", "status", 404)
+	db.Warn("request", slog.Group("http", "status", 404)) // the same label by the group attribute
+	logger.Error("fail", "component", "api")
+	logger.Error("overflow", "component", `"quoted"`)
+
+	buf := &bytes.Buffer{}
+	_, err := metrics.WriteTo(buf)
+	tt.NoError(err)
+	tt.EqualValues(strings.Join([]string{
+		`# HELP mlog_handler_dropped_total Number of log records, dropped by the handler.`,
+		`# TYPE mlog_handler_dropped_total counter`,
+		`mlog_handler_dropped_total{handler="net"} 7`,
+		`# HELP mlog_handler_errors_total Number of errors, returned by the handler.`,
+		`# TYPE mlog_handler_errors_total counter`,
+		`mlog_handler_errors_total{handler="json"} 1`,
+		`# HELP mlog_handler_records_total Number of log records, passed to the handler.`,
+		`# TYPE mlog_handler_records_total counter`,
+		`mlog_handler_records_total{handler="json"} 7`,
+		`# HELP mlog_handler_write_errors_total Number of failed writes of the handler.`,
+		`# TYPE mlog_handler_write_errors_total counter`,
+		`mlog_handler_write_errors_total{handler="net"} 2`,
+		`# HELP mlog_records_total Number of log records by level and attributes.`,
+		`# TYPE mlog_records_total counter`,
+		`mlog_records_total{component="_overflow_",level="error"} 1`,
+		`mlog_records_total{component="api",level="error"} 1`,
+		`mlog_records_total{component="db",http_status="404",level="warn"} 2`,
+		`mlog_records_total{component="db",level="info"} 2`,
+		`mlog_records_total{level="debug"} 1`,
+		``,
+	}, "\n"), buf.String())
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	tt.EqualValues(buf.String(), rec.Body.String())
+	tt.Contains(rec.Header().Get("Content-Type"), "version=0.0.4")
+
+	metrics.Publish("mlog_test_metrics")
+	var vars any
+	tt.NoError(json.Unmarshal([]byte(expvar.Get("mlog_test_metrics").String()), &vars))
+	tt.EqualValues(2.0, JqMust(t, vars, `.mlog_records_total["component=\"db\",level=\"info\""]`))
+}
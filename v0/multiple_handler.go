@@ -13,41 +13,32 @@ type MultipleHandlerOptions struct{}
 
 // MultipleHandler is a [slog.Handler] that multiply Records to each given handler as is.
 type MultipleHandler struct {
-	level    slog.Level
 	handlers []slog.Handler
 }
 
 // NewMultipleHandler creates a MultipleHandler that multiply each incoming message to each given handler,
 // using the given options. If opts is nil, the default options are used.
 func NewMultipleHandler(_ *MultipleHandlerOptions, handlerSet ...slog.Handler) *MultipleHandler {
-	h := &MultipleHandler{
+	return &MultipleHandler{
 		handlers: handlerSet,
-		level:    (^slog.Level(0) >> 1), // maximum value of slog.Level type
 	}
-exLoop:
-	for _, logLevel := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
-		for _, hh := range h.handlers {
-			if hh.Enabled(context.TODO(), logLevel) {
-				h.level = logLevel
-				break exLoop
-			}
-		}
-	}
-	return h
 }
 
 func (h *MultipleHandler) Copy() *MultipleHandler {
 	rv := &MultipleHandler{
-		level:    h.level,
 		handlers: slices.Clone(h.handlers),
 	}
 	return rv
 }
 
-// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Enabled reports whether any of the handlers handles records at the given level.
+// Levels of the handlers are checked on each call, so changes of their [slog.LevelVar] take effect at once.
+// The level override, stored in ctx by [WithLevel], is applied by handlers, which respect it, like [LevelHandler].
 // Implements [slog.Handler] interface.
-func (h *MultipleHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return level >= h.level
+func (h *MultipleHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slices.ContainsFunc(h.handlers, func(hh slog.Handler) bool {
+		return hh.Enabled(ctx, level)
+	})
 }
 
 // WithAttrs returns a new HumanReadableHandler whose attributes consists of h's attributes followed by attrs.
@@ -77,13 +68,16 @@ func (h *MultipleHandler) WithGroup(name string) slog.Handler {
 
 // Handle handles the Record.
 // It will only be called when Enabled(...) returns true.
+// The record is passed to each handler, which is enabled for its level, so each handler decides
+// whether to apply the level override, stored in ctx by [WithLevel].
+// The first error of the handlers is returned, other handlers get the record anyway.
 // Implements [slog.Handler] interface.
 func (h *MultipleHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
 	var firstErr error
 	for i := range h.handlers {
 		if h.handlers[i].Enabled(ctx, r.Level) {
 			if err := h.handlers[i].Handle(ctx, r); err != nil {
-				if firstErr != nil {
+				if firstErr == nil {
 					firstErr = err
 				}
 			}
@@ -91,3 +85,14 @@ func (h *MultipleHandler) Handle(ctx context.Context, r slog.Record) error { //n
 	}
 	return firstErr
 }
+
+// Flush flushes each handler, which implements [Flusher], and returns their errors joined.
+func (h *MultipleHandler) Flush(ctx context.Context) error {
+	return flushNext(ctx, h.handlers...)
+}
+
+// Close closes each handler, which implements [Closer], and returns their errors joined.
+// Handlers, derived by WithAttrs and WithGroup, share resources with h, so only one of them should be closed.
+func (h *MultipleHandler) Close() error {
+	return closeNext(h.handlers...)
+}
@@ -3,7 +3,10 @@ package mlog_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"slices"
 	"strings"
@@ -104,3 +107,33 @@ func Test__MtHandler__LogLevel(t *testing.T) {
 
 // todo: Test__MtHandler__With()
 // todo: Test__MtHandler__WithGroup()
+
+func Test__MtHandler__FirstError(t *testing.T) {
+	tt := assert.New(t)
+
+	errFirst := errors.New("first")
+	errSecond := errors.New("second")
+	buf := &bytes.Buffer{}
+	h := mlog.NewMultipleHandler(nil,
+		slog.NewJSONHandler(io.Discard, nil),
+		errorHandler{slog.NewJSONHandler(io.Discard, nil), errFirst},
+		slog.NewJSONHandler(buf, nil),
+		errorHandler{slog.NewJSONHandler(io.Discard, nil), errSecond},
+	)
+
+	err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0))
+	tt.ErrorIs(err, errFirst)
+	tt.NotErrorIs(err, errSecond)
+	tt.Contains(buf.String(), `"msg":"msg"`)
+	tt.False(mlog.NewMultipleHandler(nil).Enabled(context.Background(), mlog.LevelFatal))
+}
+
+// errorHandler returns the error for each record.
+type errorHandler struct {
+	slog.Handler
+	err error
+}
+
+func (h errorHandler) Handle(context.Context, slog.Record) error { //nolint:gocritic
+	return h.err
+}
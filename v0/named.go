@@ -0,0 +1,65 @@
+package mlog
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// Loggers creates hierarchical named loggers, like "db" and "db.pool". Each logger has [LoggerKey] attribute
+// with its name, which [HumanReadableHandler] shows before the message, and its own level in the [Registry]:
+// the level is inherited from the parent unless it is set explicitly, so it can be changed at runtime
+// for one logger or for the whole subtree, see [Registry.SetLevel].
+type Loggers struct {
+	registry *Registry
+	root     *SwapHandler
+
+	mu      sync.Mutex
+	loggers map[string]*slog.Logger
+}
+
+// DefaultLoggers creates loggers, returned by [Named]. Its levels are kept by [DefaultRegistry].
+var DefaultLoggers = NewLoggers(nil, nil) //nolint:gochecknoglobals
+
+// NewLoggers creates Loggers, which pass records to the given handler and keep levels in the given registry.
+// If handler is nil, the handler of [slog.Default] at the first call of [Loggers.Named] is used.
+// If registry is nil, [DefaultRegistry] is used.
+func NewLoggers(handler slog.Handler, registry *Registry) *Loggers {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	return &Loggers{
+		registry: registry,
+		root:     NewSwapHandler(handler),
+		loggers:  map[string]*slog.Logger{},
+	}
+}
+
+// Named returns the logger with the given name from [DefaultLoggers].
+func Named(name string) *slog.Logger {
+	return DefaultLoggers.Named(name)
+}
+
+// Named returns the logger with the given name, the same logger is returned for the same name.
+// Records are passed to the handler of l regardless of its own level, the logger's level is used instead.
+func (l *Loggers) Named(name string) *slog.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if logger, ok := l.loggers[name]; ok {
+		return logger
+	}
+	if l.root.Handler() == nil {
+		l.root.Swap(slog.Default().Handler())
+	}
+	entry := l.registry.entryOf(name)
+	logger := slog.New(&registeredHandler{
+		entry: entry,
+		next:  l.root.WithAttrs([]slog.Attr{slog.String(LoggerKey, name)}),
+	})
+	l.loggers[name] = logger
+	return logger
+}
+
+// SetHandler replaces the handler of all loggers, created by l.
+func (l *Loggers) SetHandler(handler slog.Handler) {
+	l.root.Swap(handler)
+}
@@ -0,0 +1,84 @@
+package mlog_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__Loggers__Inheritance(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	reg := mlog.NewRegistry()
+	loggers := mlog.NewLoggers(mlog.NewHumanReadableHandler(buf, nil), reg)
+
+	db := loggers.Named("db")
+	pool := loggers.Named("db.pool")
+	tt.Same(pool, loggers.Named("db.pool"))
+
+	pool.Debug("hidden")
+	tt.NoError(reg.SetLevel("db", slog.LevelDebug, 0))
+	pool.Debug("inherited", "n", 1)
+	db.With("a", 1).Debug("own")
+
+	tt.NoError(reg.SetLevel("db.pool", slog.LevelWarn, 0))
+	tt.NoError(reg.SetLevel("db", slog.LevelError, 0))
+	pool.Info("overridden")
+	pool.Warn("shown")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	tt.Len(lines, 3)
+	tt.Contains(lines[0], `D --  <db.pool>  inherited  ATTRS={"n":1}`)
+	tt.Contains(lines[1], `D --  <db>  own  ATTRS={"a":1}`)
+	tt.Contains(lines[2], `W --  <db.pool>  shown`)
+
+	entries := reg.Entries()
+	tt.Len(entries, 2)
+	tt.EqualValues("db", entries[0].Name)
+	tt.False(entries[0].Inherited)
+	tt.EqualValues(mlog.HandlerCounters{Debug: 1, Warn: 1}, *entries[1].Counters)
+
+	buf.Reset()
+	json := &bytes.Buffer{}
+	loggers.SetHandler(slog.NewJSONHandler(json, nil))
+	pool.Error("moved")
+	tt.Empty(buf.String())
+	tt.Contains(json.String(), `"msg":"moved","logger":"db.pool"`)
+}
+
+func Test__Loggers__ParentAndRegister(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	reg := mlog.NewRegistry()
+	loggers := mlog.NewLoggers(mlog.NewHumanReadableHandler(buf, nil), reg)
+
+	pool := loggers.Named("db.pool")
+	tt.NoError(reg.SetLevel("db", slog.LevelDebug, 0)) // the parent has no logger
+	pool.Debug("inherited")
+	tt.Error(reg.SetLevel("cache", slog.LevelDebug, 0))
+	tt.NoError(reg.SetLevel("db", slog.LevelInfo, 0))
+	pool.DebugContext(mlog.WithLevel(context.Background(), slog.LevelDebug), "override")
+
+	level := &slog.LevelVar{}
+	level.Set(slog.LevelError)
+	reg.Register("db.pool", level, slog.NewTextHandler(buf, nil))
+	pool.Warn("hidden")
+	level.Set(slog.LevelWarn)
+	pool.Warn("shown")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	tt.Len(lines, 3)
+	tt.Contains(lines[0], `D --  <db.pool>  inherited`)
+	tt.Contains(lines[1], `D --  <db.pool>  override`)
+	tt.Contains(lines[2], `W --  <db.pool>  shown`)
+	entry, ok := reg.Entry("db.pool")
+	tt.True(ok)
+	tt.EqualValues(mlog.HandlerCounters{Debug: 2, Warn: 1}, *entry.Counters)
+}
@@ -0,0 +1,310 @@
+package mlog
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+type NetWriterOptions struct {
+	// Network is "tcp", "udp", "unix" or "unixgram".
+	Network string
+	// Addr is the collector address or unix socket path.
+	Addr string
+	// DialTimeout limits time to connect and to write one message. If zero, [DefaultDialTimeout] is used.
+	DialTimeout time.Duration
+
+	// MinBackoff and MaxBackoff limit the exponentially growing delay between reconnect attempts.
+	// If zero, [DefaultMinBackoff] and [DefaultMaxBackoff] are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// BufferSize limits size (in bytes) of messages, buffered in memory while the collector is unavailable.
+	// If zero, [DefaultNetWriterBufferSize] is used.
+	BufferSize int
+
+	// SpoolDir enables on-disk spool: messages, which don't fit into the memory buffer, are written
+	// into segment files in this directory and sent after reconnect, keeping the order.
+	// Segments, left by the previous run, are sent too. Messages are sent at least once,
+	// i.e. the segment, which was partially sent before restart, will be sent from the beginning.
+	SpoolDir string
+	// MaxSegmentSize limits size of one spool segment file. If zero, [DefaultSpoolSegmentSize] is used.
+	MaxSegmentSize int64
+	// MaxSpoolSize limits the total size of the spool. If zero, the spool size is not limited.
+	MaxSpoolSize int64
+
+	// CloseTimeout limits time to send buffered messages at Close. If zero, [DefaultCloseTimeout] is used.
+	CloseTimeout time.Duration
+}
+
+// NetWriter is an [io.Writer], which sends messages to the network log collector (TCP, UDP or unix socket).
+// It can be used as output of any handler, like [HumanReadableHandler] or [slog.JSONHandler].
+// Write never blocks on the network: each written buffer is queued as one message (one datagram
+// for UDP) and sent by the background goroutine, which reconnects with exponential backoff
+// after the collector failure. Messages are buffered in memory and optionally spooled to disk,
+// so no messages are lost during collector outage.
+type NetWriter struct {
+	opts NetWriterOptions
+
+	mu          sync.Mutex
+	cond        *sync.Cond
+	queue       [][]byte
+	queuedBytes int
+	spool       *diskSpool
+	closed      bool
+	closing     chan struct{}
+	done        chan struct{}
+
+	conn net.Conn // used by the background goroutine only
+
+	dropped  atomic.Uint64
+	failures atomic.Uint64
+}
+
+// NewNetWriter creates a NetWriter and starts the background sending goroutine.
+// Error is returned if the spool directory can't be opened.
+func NewNetWriter(opts *NetWriterOptions) (*NetWriter, error) {
+	w := &NetWriter{
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.DialTimeout == 0 {
+		w.opts.DialTimeout = DefaultDialTimeout
+	}
+	if w.opts.MinBackoff == 0 {
+		w.opts.MinBackoff = DefaultMinBackoff
+	}
+	if w.opts.MaxBackoff < w.opts.MinBackoff {
+		w.opts.MaxBackoff = max(DefaultMaxBackoff, w.opts.MinBackoff)
+	}
+	if w.opts.BufferSize == 0 {
+		w.opts.BufferSize = DefaultNetWriterBufferSize
+	}
+	if w.opts.MaxSegmentSize == 0 {
+		w.opts.MaxSegmentSize = DefaultSpoolSegmentSize
+	}
+	if w.opts.CloseTimeout == 0 {
+		w.opts.CloseTimeout = DefaultCloseTimeout
+	}
+	if w.opts.SpoolDir != "" {
+		spool, err := openDiskSpool(w.opts.SpoolDir, w.opts.MaxSegmentSize, w.opts.MaxSpoolSize)
+		if err != nil {
+			return nil, err
+		}
+		w.spool = spool
+	}
+	w.cond = sync.NewCond(&w.mu)
+	go w.run()
+	return w, nil
+}
+
+// Write queues a copy of p as one message. It returns [ErrBufferFull] if the message
+// doesn't fit into the memory buffer and the spool, and [ErrClosed] after Close.
+// Implements [io.Writer] interface.
+func (w *NetWriter) Write(p []byte) (int, error) {
+	msg := append([]byte(nil), p...)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		w.dropped.Add(1)
+		return 0, ErrClosed
+	}
+	// once the spool is used, all new messages go there, until it is drained, to keep the order
+	overflow := w.queuedBytes+len(msg) > w.opts.BufferSize && len(w.queue) != 0
+	if w.spool != nil && (overflow || !w.spool.empty()) {
+		if err := w.spool.append(msg); err != nil {
+			w.dropped.Add(1)
+			return 0, err
+		}
+	} else {
+		if overflow {
+			w.dropped.Add(1)
+			return 0, ErrBufferFull
+		}
+		w.queue = append(w.queue, msg)
+		w.queuedBytes += len(msg)
+	}
+	w.cond.Broadcast()
+	return len(p), nil
+}
+
+// Flush waits until all buffered and spooled messages are sent or ctx is done.
+func (w *NetWriter) Flush(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.cond.Broadcast()
+	})
+	defer stop()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.queue) != 0 || (w.spool != nil && !w.spool.empty()) {
+		if err := ctx.Err(); err != nil {
+			return err //nolint:wrapcheck
+		}
+		if w.closed {
+			return ErrClosed
+		}
+		w.cond.Wait()
+	}
+	return nil
+}
+
+// Close sends buffered messages during CloseTimeout, then stops the background goroutine.
+// Messages, which were not sent, are stored into the spool, if it is configured.
+// Later calls do nothing and return nil.
+func (w *NetWriter) Close() error {
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), w.opts.CloseTimeout)
+	defer cancel()
+	flushErr := w.Flush(ctx)
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.closing)
+	w.cond.Broadcast()
+	w.mu.Unlock()
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn != nil {
+		w.conn.Close()
+	}
+	if w.spool == nil {
+		if len(w.queue) != 0 {
+			w.dropped.Add(uint64(len(w.queue)))
+			return flushErr
+		}
+		return nil
+	}
+	err := w.spool.prepend(w.queue) // queued messages are older than spooled ones
+	if err != nil {
+		w.dropped.Add(uint64(len(w.queue)))
+	}
+	w.queue = nil
+	return errors.Join(err, w.spool.close())
+}
+
+// Dropped returns the number of messages, which were dropped because of buffer overflow or spool errors,
+// or because they can't be sent at all, like datagrams larger than the socket allows.
+func (w *NetWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Errors returns the number of failed connect and write attempts.
+func (w *NetWriter) Errors() uint64 {
+	return w.failures.Load()
+}
+
+// run sends messages one by one, until Close is called.
+func (w *NetWriter) run() {
+	defer close(w.done)
+	backoff := w.opts.MinBackoff
+	for {
+		msg, fromSpool, ok := w.next()
+		if !ok {
+			return
+		}
+		if err := w.send(msg); err != nil {
+			w.failures.Add(1)
+			var permErr permanentError
+			if errors.As(err, &permErr) { // retry will not fix it, the message must not block the next ones
+				w.dropped.Add(1)
+				w.pop(fromSpool)
+				continue
+			}
+			select {
+			case <-time.After(backoff):
+			case <-w.closing:
+				return
+			}
+			backoff = min(2*backoff, w.opts.MaxBackoff) //nolint:gomnd
+			continue
+		}
+		backoff = w.opts.MinBackoff
+		w.pop(fromSpool)
+	}
+}
+
+// next waits for the next message: queued messages go first, because they are older than spooled ones.
+func (w *NetWriter) next() (msg []byte, fromSpool, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for !w.closed {
+		if len(w.queue) != 0 {
+			return w.queue[0], false, true
+		}
+		if w.spool != nil && !w.spool.empty() {
+			msg, err := w.spool.peek()
+			if err != nil {
+				w.failures.Add(1)
+			}
+			if msg != nil {
+				return msg, true, true
+			}
+			if w.spool.empty() {
+				w.cond.Broadcast() // wake up Flush
+			}
+			continue
+		}
+		w.cond.Wait()
+	}
+	return nil, false, false
+}
+
+func (w *NetWriter) pop(fromSpool bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if fromSpool {
+		w.spool.advance()
+	} else {
+		w.queuedBytes -= len(w.queue[0])
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+	}
+	w.cond.Broadcast()
+}
+
+func (w *NetWriter) send(msg []byte) error {
+	if w.conn != nil && isStreamConn(w.conn) && isConnClosed(w.conn) {
+		w.conn.Close()
+		w.conn = nil
+	}
+	if w.conn == nil {
+		conn, err := net.DialTimeout(w.opts.Network, w.opts.Addr, w.opts.DialTimeout)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		w.conn = conn
+	}
+	err := w.conn.SetWriteDeadline(time.Now().Add(w.opts.DialTimeout))
+	if err == nil {
+		_, err = w.conn.Write(msg)
+	}
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+		if errors.Is(err, syscall.EMSGSIZE) {
+			return permanentError{err}
+		}
+	}
+	return err //nolint:wrapcheck
+}
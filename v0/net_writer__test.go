@@ -0,0 +1,200 @@
+package mlog_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+// freeTCPAddr returns an address, which is not listened yet.
+func freeTCPAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// collectLines accepts connections on addr and sends received lines into the returned channel.
+func collectLines(t *testing.T, addr string) <-chan string {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				sc := bufio.NewScanner(conn)
+				for sc.Scan() {
+					lines <- sc.Text()
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+func receiveLines(t *testing.T, lines <-chan string, count int) []string {
+	t.Helper()
+	rv := []string{}
+	for len(rv) < count {
+		select {
+		case line := <-lines:
+			rv = append(rv, line)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d of %d lines", len(rv), count)
+		}
+	}
+	return rv
+}
+
+func messages(from, to int) []string {
+	rv := []string{}
+	for i := from; i < to; i++ {
+		rv = append(rv, fmt.Sprintf("message %02d", i))
+	}
+	return rv
+}
+
+func Test__NetWriter__Reconnect(t *testing.T) {
+	tt := assert.New(t)
+
+	addr := freeTCPAddr(t)
+	w, err := mlog.NewNetWriter(&mlog.NetWriterOptions{
+		Network:    "tcp",
+		Addr:       addr,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+	})
+	tt.NoError(err)
+	defer w.Close()
+
+	for _, msg := range messages(0, 5) {
+		_, err := w.Write([]byte(msg + "\n"))
+		tt.NoError(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	tt.Greater(w.Errors(), uint64(0))
+
+	lines := collectLines(t, addr) // collector is started
+	tt.EqualValues(messages(0, 5), receiveLines(t, lines, 5))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tt.NoError(w.Flush(ctx))
+	tt.Zero(w.Dropped())
+}
+
+func Test__NetWriter__BufferFull(t *testing.T) {
+	tt := assert.New(t)
+
+	w, err := mlog.NewNetWriter(&mlog.NetWriterOptions{
+		Network:      "tcp",
+		Addr:         freeTCPAddr(t),
+		BufferSize:   25,
+		CloseTimeout: 10 * time.Millisecond,
+	})
+	tt.NoError(err)
+
+	for _, msg := range messages(0, 2) {
+		_, err := w.Write([]byte(msg + "\n"))
+		tt.NoError(err)
+	}
+	_, err = w.Write([]byte("overflow\n"))
+	tt.ErrorIs(err, mlog.ErrBufferFull)
+	tt.EqualValues(1, w.Dropped())
+
+	tt.Error(w.Close())
+	tt.NoError(w.Close())
+	_, err = w.Write([]byte("closed\n"))
+	tt.ErrorIs(err, mlog.ErrClosed)
+}
+
+func Test__NetWriter__Spool(t *testing.T) {
+	tt := assert.New(t)
+
+	addr := freeTCPAddr(t)
+	spoolDir := t.TempDir()
+	opts := &mlog.NetWriterOptions{
+		Network:        "tcp",
+		Addr:           addr,
+		MinBackoff:     10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		BufferSize:     30,
+		SpoolDir:       spoolDir,
+		MaxSegmentSize: 64,
+		CloseTimeout:   10 * time.Millisecond,
+	}
+	w, err := mlog.NewNetWriter(opts)
+	tt.NoError(err)
+
+	for _, msg := range messages(0, 20) {
+		_, err := w.Write([]byte(msg + "\n"))
+		tt.NoError(err)
+	}
+	entries, err := os.ReadDir(spoolDir)
+	tt.NoError(err)
+	tt.Greater(len(entries), 1) // messages are spooled into several segments
+
+	tt.NoError(w.Close()) // collector is unavailable, the memory buffer is spooled too
+	tt.Zero(w.Dropped())
+
+	// restarted application sends the spool of the previous run
+	w, err = mlog.NewNetWriter(opts)
+	tt.NoError(err)
+	defer w.Close()
+	_, err = w.Write([]byte("message 20\n"))
+	tt.NoError(err)
+
+	lines := collectLines(t, addr)
+	tt.EqualValues(messages(0, 21), receiveLines(t, lines, 21))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tt.NoError(w.Flush(ctx))
+	entries, err = os.ReadDir(spoolDir)
+	tt.NoError(err)
+	tt.Empty(entries)
+}
+
+func Test__NetWriter__TooLarge(t *testing.T) {
+	tt := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	tt.NoError(err)
+	defer conn.Close()
+	w, err := mlog.NewNetWriter(&mlog.NetWriterOptions{
+		Network:    "udp",
+		Addr:       conn.LocalAddr().String(),
+		BufferSize: 1 << 20,
+		MinBackoff: time.Hour, // the message would block the queue, if it were retried
+	})
+	tt.NoError(err)
+	defer w.Close()
+
+	_, err = w.Write(make([]byte, 70000)) // larger than UDP allows
+	tt.NoError(err)
+	_, err = w.Write([]byte("small"))
+	tt.NoError(err)
+
+	buf := make([]byte, 100)
+	tt.NoError(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	tt.NoError(err)
+	tt.EqualValues("small", string(buf[:n]))
+	tt.EqualValues(1, w.Dropped())
+}
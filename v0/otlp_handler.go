@@ -0,0 +1,396 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLPProtocol is an encoding of OTLP export requests.
+type OTLPProtocol int
+
+const (
+	OTLPProtocolHTTPProtobuf OTLPProtocol = iota
+	OTLPProtocolHTTPJSON
+)
+
+type OTLPHandlerOptions struct {
+	// Endpoint is the collector URL, like "http://localhost:4318". If the URL has no path, "/v1/logs" is used.
+	// If empty, [DefaultOTLPEndpoint] is used.
+	Endpoint string
+	// Protocol is the request encoding, protobuf by default.
+	Protocol OTLPProtocol
+	// Headers are added to each request, for example to pass the authorization token.
+	Headers map[string]string
+	// Gzip enables compression of the requests.
+	Gzip bool
+	// Client is used to send requests. If nil, the client with [DefaultHTTPTimeout] is used.
+	Client *http.Client
+
+	// ServiceName is the "service.name" resource attribute. If empty, "unknown_service:<executable name>" is used.
+	ServiceName string
+	// ResourceAttrs are added to the resource attributes, like "service.version" or "deployment.environment".
+	ResourceAttrs []slog.Attr
+
+	// AddSource causes the handler to add "code.filepath", "code.lineno" and "code.function" attributes
+	// with the source code position of the log statement.
+	AddSource bool
+
+	// Batch configures batching and retries of the requests.
+	Batch BatchOptions
+
+	// MaxAttrDepth and MaxAttrSize limit attribute values, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to log.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelInfo].
+	Level slog.Leveler
+}
+
+// OTLPHandler is a [slog.Handler] that exports Records to the OpenTelemetry collector
+// as OTLP LogRecords over HTTP, using protobuf or JSON encoding.
+// Records are queued and sent in batches by the background goroutine, so Handle never blocks on the network.
+// Groups are sent as nested key-value lists, trace and span IDs are taken from the context (see [WithTrace]).
+type OTLPHandler struct {
+	opts    OTLPHandlerOptions
+	state   handlerState
+	batcher *batcher[otlpRecord]
+}
+
+// otlpRecord is the OTLP LogRecord, waiting in the queue.
+type otlpRecord struct {
+	time     time.Time
+	observed time.Time
+	level    slog.Level
+	body     string
+	attrs    jsonTree
+	trace    TraceContext
+}
+
+// NewOTLPHandler creates an OTLPHandler, using the given options, and starts the background sending goroutine.
+func NewOTLPHandler(opts *OTLPHandlerOptions) *OTLPHandler {
+	h := &OTLPHandler{}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	if h.opts.Endpoint == "" {
+		h.opts.Endpoint = DefaultOTLPEndpoint
+	}
+	if h.opts.Client == nil {
+		h.opts.Client = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+	if h.opts.ServiceName == "" {
+		h.opts.ServiceName = "unknown_service:" + filepath.Base(os.Args[0])
+	}
+	h.state = newHandlerState(newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize))
+
+	endpoint := h.opts.Endpoint
+	if u, err := url.Parse(endpoint); err == nil && strings.Trim(u.Path, "/") == "" {
+		u.Path = otlpLogsPath
+		endpoint = u.String()
+	}
+	sender := httpSender{client: h.opts.Client, url: endpoint, headers: h.opts.Headers, gzip: h.opts.Gzip}
+	resource := jsonTree{"service.name": h.opts.ServiceName}
+	for _, a := range h.opts.ResourceAttrs {
+		h.state.enc.addAttr(resource, a, 0)
+	}
+	h.batcher = newBatcher(h.opts.Batch, func(ctx context.Context, batch []otlpRecord) error {
+		if h.opts.Protocol == OTLPProtocolHTTPJSON {
+			body, err := json.Marshal(otlpJSONRequest(resource, batch))
+			if err != nil {
+				return permanentError{err}
+			}
+			_, err = sender.post(ctx, "application/json", body)
+			return err
+		}
+		_, err := sender.post(ctx, "application/x-protobuf", otlpProtoRequest(resource, batch))
+		return err
+	})
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *OTLPHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle converts the Record into OTLP LogRecord and queues it.
+// [ErrBufferFull] is returned if the queue is full.
+// Implements [slog.Handler] interface.
+func (h *OTLPHandler) Handle(ctx context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
+	rec := otlpRecord{
+		time:     r.Time,
+		observed: time.Now(),
+		level:    r.Level,
+		body:     r.Message,
+	}
+	rec.attrs, _ = h.state.tree(&r)
+	if h.opts.AddSource && r.PC != 0 {
+		source := DecodeSource(r.PC)
+		rec.attrs["code.filepath"] = source.File
+		rec.attrs["code.lineno"] = int64(source.Line)
+		rec.attrs["code.function"] = source.Function
+	}
+	rec.trace, _ = TraceFromContext(ctx)
+	if err := h.batcher.add(rec); err != nil {
+		return errors.Join(Error, err)
+	}
+	return nil
+}
+
+// WithAttrs returns a new OTLPHandler whose attributes consists of h's attributes followed by attrs.
+// Implements [slog.Handler] interface.
+func (h *OTLPHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &OTLPHandler{
+		opts:    h.opts,
+		state:   h.state.withAttrs(aa),
+		batcher: h.batcher,
+	}
+}
+
+// WithGroup returns a new OTLPHandler with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *OTLPHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &OTLPHandler{
+		opts:    h.opts,
+		state:   h.state.withGroup(name),
+		batcher: h.batcher,
+	}
+}
+
+// Flush sends all queued records and returns the error of the last failed request.
+func (h *OTLPHandler) Flush(ctx context.Context) error {
+	return h.batcher.flush(ctx)
+}
+
+// Close sends queued records during [BatchOptions.CloseTimeout] and stops the background goroutine.
+// The queue is shared by all handlers, derived by WithAttrs and WithGroup.
+func (h *OTLPHandler) Close() error {
+	return h.batcher.close()
+}
+
+// Dropped returns the number of records, which were dropped because of queue overflow or failed requests.
+func (h *OTLPHandler) Dropped() uint64 {
+	return h.batcher.dropped.Load()
+}
+
+// -----------------------------------------------------------------------------
+
+// LevelToSeverityNumber converts [slog.Level] into OpenTelemetry severity number:
+// DEBUG is 5, INFO is 9, WARN is 13, ERROR is 17, levels between them map to the numbers between.
+func LevelToSeverityNumber(level slog.Level) int {
+	return min(max(int(level)+otlpSeverityInfo-int(slog.LevelInfo), 1), otlpSeverityMax)
+}
+
+// otlpNormalize converts values, which have no OTLP representation (like [json.RawMessage]),
+// into strings, numbers, bools, lists and trees.
+func otlpNormalize(v any) any {
+	switch vv := v.(type) {
+	case nil, string, bool, int64, float64, jsonTree, []any:
+		return v
+	case uint64:
+		if vv > math.MaxInt64 {
+			return strconv.FormatUint(vv, 10)
+		}
+		return int64(vv)
+	case map[string]any:
+		return jsonTree(vv)
+	case json.Number:
+		if i, err := vv.Int64(); err == nil {
+			return i
+		}
+		f, _ := vv.Float64()
+		return f
+	}
+	b, ok := v.(json.RawMessage)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return formatScalar(v)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var rv any
+	if err := dec.Decode(&rv); err != nil {
+		return string(b)
+	}
+	return otlpNormalize(rv)
+}
+
+func otlpJSONRequest(resource jsonTree, batch []otlpRecord) map[string]any {
+	records := make([]any, 0, len(batch))
+	for i := range batch {
+		r := &batch[i]
+		rec := map[string]any{
+			"timeUnixNano":         strconv.FormatInt(unixNano(r.time), 10),
+			"observedTimeUnixNano": strconv.FormatInt(unixNano(r.observed), 10),
+			"severityNumber":       LevelToSeverityNumber(r.level),
+			"severityText":         LevelString(r.level),
+			"body":                 map[string]any{"stringValue": r.body},
+			"attributes":           otlpJSONKeyValues(r.attrs),
+		}
+		if r.trace.IsValid() {
+			rec["traceId"] = hex.EncodeToString(r.trace.TraceID[:])
+			rec["spanId"] = hex.EncodeToString(r.trace.SpanID[:])
+			rec["flags"] = int(r.trace.Flags)
+		}
+		records = append(records, rec)
+	}
+	return map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpJSONKeyValues(resource)},
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]any{"name": otlpScopeName},
+				"logRecords": records,
+			}},
+		}},
+	}
+}
+
+func otlpJSONKeyValues(tree jsonTree) []any {
+	rv := make([]any, 0, len(tree))
+	for _, k := range sortedKeys(tree) {
+		rv = append(rv, map[string]any{"key": k, "value": otlpJSONValue(tree[k])})
+	}
+	return rv
+}
+
+// otlpJSONValue returns the AnyValue in the OTLP/JSON form, where 64-bit integers are strings.
+func otlpJSONValue(v any) map[string]any {
+	switch vv := otlpNormalize(v).(type) {
+	case nil:
+		return map[string]any{}
+	case string:
+		return map[string]any{"stringValue": vv}
+	case bool:
+		return map[string]any{"boolValue": vv}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(vv, 10)}
+	case float64:
+		return map[string]any{"doubleValue": vv}
+	case jsonTree:
+		return map[string]any{"kvlistValue": map[string]any{"values": otlpJSONKeyValues(vv)}}
+	case []any:
+		values := make([]any, 0, len(vv))
+		for i := range vv {
+			values = append(values, otlpJSONValue(vv[i]))
+		}
+		return map[string]any{"arrayValue": map[string]any{"values": values}}
+	}
+	return map[string]any{"stringValue": formatScalar(v)}
+}
+
+// otlpProtoRequest encodes opentelemetry.proto.collector.logs.v1.ExportLogsServiceRequest.
+func otlpProtoRequest(resource jsonTree, batch []otlpRecord) []byte {
+	return protoAppendMessage(nil, 1, func(b []byte) []byte { // ResourceLogs resource_logs = 1
+		b = protoAppendMessage(b, 1, func(b []byte) []byte { // Resource resource = 1
+			return otlpProtoKeyValues(b, 1, resource) // KeyValue attributes = 1
+		})
+		return protoAppendMessage(b, 2, func(b []byte) []byte { // ScopeLogs scope_logs = 2
+			b = protoAppendMessage(b, 1, func(b []byte) []byte { // InstrumentationScope scope = 1
+				return protoAppendString(b, 1, otlpScopeName)
+			})
+			for i := range batch {
+				b = protoAppendMessage(b, 2, func(b []byte) []byte { // LogRecord log_records = 2
+					return otlpProtoRecord(b, &batch[i])
+				})
+			}
+			return b
+		})
+	})
+}
+
+func otlpProtoRecord(b []byte, r *otlpRecord) []byte {
+	b = protoAppendFixed64(b, 1, uint64(unixNano(r.time)))
+	b = protoAppendVarint(b, 2, uint64(LevelToSeverityNumber(r.level)))
+	b = protoAppendString(b, 3, LevelString(r.level))
+	b = protoAppendMessage(b, 5, func(b []byte) []byte {
+		return protoAppendString(b, 1, r.body)
+	})
+	b = otlpProtoKeyValues(b, 6, r.attrs)
+	if r.trace.IsValid() {
+		b = protoAppendFixed32(b, 8, uint32(r.trace.Flags))
+		b = protoAppendBytes(b, 9, r.trace.TraceID[:])
+		b = protoAppendBytes(b, 10, r.trace.SpanID[:])
+	}
+	return protoAppendFixed64(b, 11, uint64(unixNano(r.observed)))
+}
+
+func otlpProtoKeyValues(b []byte, field int, tree jsonTree) []byte {
+	for _, k := range sortedKeys(tree) {
+		b = protoAppendMessage(b, field, func(b []byte) []byte {
+			b = protoAppendString(b, 1, k)
+			return protoAppendMessage(b, 2, func(b []byte) []byte {
+				return otlpProtoValue(b, tree[k])
+			})
+		})
+	}
+	return b
+}
+
+// otlpProtoValue encodes fields of the AnyValue message.
+func otlpProtoValue(b []byte, v any) []byte {
+	switch vv := otlpNormalize(v).(type) {
+	case nil:
+		return b
+	case string:
+		return protoAppendString(b, 1, vv)
+	case bool:
+		if vv {
+			return protoAppendVarint(b, 2, 1)
+		}
+		return protoAppendVarint(b, 2, 0)
+	case int64:
+		return protoAppendVarint(b, 3, uint64(vv))
+	case float64:
+		return protoAppendDouble(b, 4, vv)
+	case []any:
+		return protoAppendMessage(b, 5, func(b []byte) []byte {
+			for i := range vv {
+				b = protoAppendMessage(b, 1, func(b []byte) []byte {
+					return otlpProtoValue(b, vv[i])
+				})
+			}
+			return b
+		})
+	case jsonTree:
+		return protoAppendMessage(b, 6, func(b []byte) []byte {
+			return otlpProtoKeyValues(b, 1, vv)
+		})
+	}
+	return protoAppendString(b, 1, formatScalar(v))
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,204 @@
+package mlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+// collector is a stand-in of HTTP log collector, it stores request bodies
+// and replies with the given statuses, then with 200.
+type collector struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+	server   *httptest.Server
+}
+
+func newCollector(t *testing.T, statuses ...int) *collector {
+	t.Helper()
+	c := &collector{statuses: statuses}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if len(c.statuses) != 0 {
+			status := c.statuses[0]
+			c.statuses = c.statuses[1:]
+			http.Error(w, "failed", status)
+			return
+		}
+		c.requests = append(c.requests, r)
+		c.bodies = append(c.bodies, body)
+	}))
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+func (c *collector) received() ([]*http.Request, [][]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests, c.bodies
+}
+
+func flushCtx(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func Test__OTLPHandler__JSON(t *testing.T) {
+	tt := assert.New(t)
+
+	c := newCollector(t)
+	h := mlog.NewOTLPHandler(&mlog.OTLPHandlerOptions{
+		Endpoint:      c.server.URL,
+		Protocol:      mlog.OTLPProtocolHTTPJSON,
+		Headers:       map[string]string{"Authorization": "Bearer token"},
+		ServiceName:   "svc",
+		ResourceAttrs: []slog.Attr{slog.String("service.version", "1.2.3")},
+		Level:         slog.LevelDebug,
+	})
+	defer h.Close()
+	ctx, err := mlog.WithTraceParent(context.Background(), traceParent)
+	tt.NoError(err)
+
+	logger := slog.New(h).With("a", 1).WithGroup("g")
+	logger.WarnContext(ctx, "hello otlp", "b", "x", "n", 2.5)
+	logger.Debug("second")
+	tt.NoError(h.Flush(flushCtx(t)))
+
+	requests, bodies := c.received()
+	tt.Len(requests, 1)
+	tt.EqualValues("/v1/logs", requests[0].URL.Path)
+	tt.EqualValues("application/json", requests[0].Header.Get("Content-Type"))
+	tt.EqualValues("Bearer token", requests[0].Header.Get("Authorization"))
+
+	data := map[string]any{}
+	tt.NoError(json.Unmarshal(bodies[0], &data))
+	rl := JqMust(t, data, ".resourceLogs[0]")
+	tt.EqualValues(
+		[]any{
+			map[string]any{"key": "service.name", "value": map[string]any{"stringValue": "svc"}},
+			map[string]any{"key": "service.version", "value": map[string]any{"stringValue": "1.2.3"}},
+		},
+		JqMust(t, rl, ".resource.attributes"))
+
+	records := JqMust(t, rl, ".scopeLogs[0].logRecords").([]any)
+	tt.Len(records, 2)
+	tt.EqualValues(13, JqMust(t, records[0], ".severityNumber"))
+	tt.EqualValues("WARN", JqMust(t, records[0], ".severityText"))
+	tt.EqualValues("hello otlp", JqMust(t, records[0], ".body.stringValue"))
+	tt.EqualValues("4bf92f3577b34da6a3ce929d0e0e4736", JqMust(t, records[0], ".traceId"))
+	tt.EqualValues("00f067aa0ba902b7", JqMust(t, records[0], ".spanId"))
+	tt.EqualValues(
+		[]any{
+			map[string]any{"key": "a", "value": map[string]any{"intValue": "1"}},
+			map[string]any{"key": "g", "value": map[string]any{"kvlistValue": map[string]any{"values": []any{
+				map[string]any{"key": "b", "value": map[string]any{"stringValue": "x"}},
+				map[string]any{"key": "n", "value": map[string]any{"doubleValue": 2.5}},
+			}}}},
+		},
+		JqMust(t, records[0], ".attributes"))
+
+	tt.EqualValues(5, JqMust(t, records[1], ".severityNumber"))
+	tt.Nil(JqMust(t, records[1], ".traceId"))
+}
+
+func Test__OTLPHandler__Protobuf_Retry(t *testing.T) {
+	tt := assert.New(t)
+
+	c := newCollector(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	h := mlog.NewOTLPHandler(&mlog.OTLPHandlerOptions{
+		Endpoint: c.server.URL + "/custom/logs",
+		Batch:    mlog.BatchOptions{MinBackoff: time.Millisecond},
+	})
+	defer h.Close()
+
+	slog.New(h).Info("hello protobuf", "key", "value")
+	tt.NoError(h.Flush(flushCtx(t)))
+
+	requests, bodies := c.received()
+	tt.Len(requests, 1)
+	tt.EqualValues("/custom/logs", requests[0].URL.Path)
+	tt.EqualValues("application/x-protobuf", requests[0].Header.Get("Content-Type"))
+	tt.True(bytes.Contains(bodies[0], []byte("hello protobuf")))
+	tt.True(bytes.Contains(bodies[0], []byte("\n\x03key\x12\x07\n\x05value"))) // KeyValue{key, AnyValue{string_value}}
+	tt.Zero(h.Dropped())
+}
+
+func Test__OTLPHandler__PermanentError(t *testing.T) {
+	tt := assert.New(t)
+
+	c := newCollector(t, http.StatusBadRequest)
+	h := mlog.NewOTLPHandler(&mlog.OTLPHandlerOptions{
+		Endpoint: c.server.URL,
+		Batch:    mlog.BatchOptions{MinBackoff: time.Millisecond},
+	})
+
+	slog.New(h).Info("rejected")
+	tt.ErrorIs(h.Flush(flushCtx(t)), mlog.ErrUnexpectedStatus)
+	tt.EqualValues(1, h.Dropped())
+
+	requests, _ := c.received()
+	tt.Empty(requests)
+	tt.NoError(h.Close())
+	tt.NoError(h.Close()) // closing again is no-op
+}
+
+func Test__OTLPHandler__CloseConcurrent(t *testing.T) {
+	tt := assert.New(t)
+
+	c := newCollector(t)
+	h := mlog.NewOTLPHandler(&mlog.OTLPHandlerOptions{
+		Endpoint: c.server.URL,
+		Protocol: mlog.OTLPProtocolHTTPJSON,
+	})
+	logger := slog.New(h)
+
+	const workers, records = 8, 200
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < records; j++ {
+				logger.Info("record")
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	_ = h.Close()
+	wg.Wait()
+
+	sent := 0
+	_, bodies := c.received()
+	for _, body := range bodies {
+		data := map[string]any{}
+		tt.NoError(json.Unmarshal(body, &data))
+		sent += len(JqMust(t, data, ".resourceLogs[0].scopeLogs[0].logRecords").([]any))
+	}
+	tt.EqualValues(workers*records, uint64(sent)+h.Dropped()) // each record is either sent or counted
+}
+
+func Test__OTLPHandler__SeverityNumber(t *testing.T) {
+	tt := assert.New(t)
+
+	tt.EqualValues(1, mlog.LevelToSeverityNumber(slog.LevelDebug-10))
+	tt.EqualValues(5, mlog.LevelToSeverityNumber(slog.LevelDebug))
+	tt.EqualValues(9, mlog.LevelToSeverityNumber(slog.LevelInfo))
+	tt.EqualValues(17, mlog.LevelToSeverityNumber(slog.LevelError))
+	tt.EqualValues(24, mlog.LevelToSeverityNumber(slog.LevelError+100))
+}
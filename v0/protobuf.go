@@ -0,0 +1,50 @@
+package mlog
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Minimal protocol buffers encoder, enough to build OTLP and Loki requests without generated code.
+
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+func protoAppendTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func protoAppendVarint(b []byte, field int, v uint64) []byte {
+	return binary.AppendUvarint(protoAppendTag(b, field, protoVarint), v)
+}
+
+func protoAppendFixed64(b []byte, field int, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(protoAppendTag(b, field, protoFixed64), v)
+}
+
+func protoAppendFixed32(b []byte, field int, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(protoAppendTag(b, field, protoFixed32), v)
+}
+
+func protoAppendDouble(b []byte, field int, v float64) []byte {
+	return protoAppendFixed64(b, field, math.Float64bits(v))
+}
+
+func protoAppendBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(protoAppendTag(b, field, protoBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func protoAppendString(b []byte, field int, v string) []byte {
+	b = binary.AppendUvarint(protoAppendTag(b, field, protoBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+// protoAppendMessage appends the embedded message, encoded by fn.
+func protoAppendMessage(b []byte, field int, fn func(b []byte) []byte) []byte {
+	return protoAppendBytes(b, field, fn(nil))
+}
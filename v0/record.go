@@ -0,0 +1,46 @@
+package mlog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// NewRecord creates a [slog.Record] with the source code position of the caller.
+// callerSkip is a number of stack frames to skip: 0 means the function, which calls NewRecord,
+// 1 means its caller, and so on. Logging wrappers should add own depth to callerSkip,
+// so the [HumanReadableHandlerOptions.AddSource] will show the right position.
+func NewRecord(t time.Time, level slog.Level, msg string, callerSkip int) slog.Record {
+	var pcs [1]uintptr
+	runtime.Callers(callerSkip+2, pcs[:]) //nolint:gomnd // skip runtime.Callers() and NewRecord()
+	return slog.NewRecord(t, level, msg, pcs[0])
+}
+
+// LogSkip emits the log record with the given level and message by the logger,
+// like [slog.Logger.Log] does, but with source code position of the caller, skipped callerSkip frames.
+// 0 means the function, which calls LogSkip.
+func LogSkip(ctx context.Context, logger *slog.Logger, callerSkip int, level slog.Level, msg string, args ...any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	r := NewRecord(time.Now(), level, msg, callerSkip+1)
+	r.Add(args...)
+	_ = logger.Handler().Handle(ctx, r)
+}
+
+// LogAttrsSkip is a more efficient version of [LogSkip] that accepts only Attrs.
+func LogAttrsSkip(ctx context.Context, logger *slog.Logger, callerSkip int, level slog.Level, msg string, attrs ...slog.Attr) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	r := NewRecord(time.Now(), level, msg, callerSkip+1)
+	r.AddAttrs(attrs...)
+	_ = logger.Handler().Handle(ctx, r)
+}
@@ -0,0 +1,424 @@
+package mlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Registry keeps levels of named handlers and loggers, so they can be listed and changed at runtime,
+// permanently or temporarily, for example by the operator through the HTTP endpoint (see [Registry.ServeHTTP]):
+//
+//	http.Handle("/debug/log", mlog.DefaultRegistry)
+//
+// Names are hierarchical, parts are separated by dot: levels, which are not set explicitly, are inherited
+// from the nearest parent with explicitly set level, like "db.pool" from "db", or [slog.LevelInfo] if there is none.
+type Registry struct {
+	mu      sync.Mutex
+	entries map[string]*registryEntry
+}
+
+// DefaultRegistry is the registry, used by default.
+var DefaultRegistry = NewRegistry() //nolint:gochecknoglobals
+
+type registryEntry struct {
+	level    atomic.Pointer[slog.LevelVar] // replaced by Register, while handlers read it
+	explicit bool                          // the level is set explicitly, otherwise it is inherited
+	base     slog.Level                    // the level, restored when the temporary override expires
+	baseSet  bool                          // base is set explicitly
+	expires  time.Time                     // zero, if the level is not overridden temporarily
+	timer    *time.Timer
+	counters *handlerCounters // nil for levels without handler
+	next     slog.Handler
+}
+
+type handlerCounters struct {
+	debug, info, warn, error atomic.Uint64
+	errors                   atomic.Uint64
+}
+
+// RegistryEntry describes the registered level, it is returned by [Registry.Entries] and the HTTP endpoint.
+type RegistryEntry struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+	// Inherited is true if the level is inherited from the parent.
+	Inherited bool `json:"inherited,omitempty"`
+	// BaseLevel and ExpiresAt are set while the level is overridden temporarily,
+	// BaseLevel is restored at ExpiresAt.
+	BaseLevel string     `json:"base_level,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Counters are set for registered handlers, see [Registry.Register].
+	Counters *HandlerCounters `json:"counters,omitempty"`
+}
+
+// HandlerCounters are numbers of records, passed to the registered handler, by level,
+// the number of errors, returned by the handler, and the number of records, dropped by it
+// (if the handler reports it, like [OTLPHandler] does). Levels are counted by [slog] ranges:
+// [LevelTrace] is counted as debug, [LevelNotice] as info, [LevelCritical] and [LevelFatal] as error.
+type HandlerCounters struct {
+	Debug   uint64 `json:"debug"`
+	Info    uint64 `json:"info"`
+	Warn    uint64 `json:"warn"`
+	Error   uint64 `json:"error"`
+	Errors  uint64 `json:"errors"`
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{entries: map[string]*registryEntry{}}
+}
+
+// Register registers the level of the handler under the given name and returns the handler,
+// which logs records at or above the level, or the level override of [WithLevel], and counts them. Records are passed to next regardless
+// of its own level. If level is nil, a new [slog.LevelVar] with the inherited level is used.
+// If the name is already registered, for example by [Loggers.Named], the registration is updated,
+// so existing loggers with this name use the given level; if level is nil, the current one is kept.
+func (reg *Registry) Register(name string, level *slog.LevelVar, next slog.Handler) slog.Handler {
+	e := reg.add(name, level, next)
+	return &registeredHandler{entry: e, next: next}
+}
+
+// RegisterLevel registers the level without the handler under the given name, see [Registry.Register].
+func (reg *Registry) RegisterLevel(name string, level *slog.LevelVar) {
+	reg.add(name, level, nil)
+}
+
+func (reg *Registry) add(name string, level *slog.LevelVar, next slog.Handler) *registryEntry {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	e, ok := reg.entries[name]
+	if !ok {
+		e = newRegistryEntry()
+		reg.entries[name] = e
+	}
+	if level != nil {
+		if e.timer != nil {
+			e.timer.Stop()
+			e.timer = nil
+			e.expires = time.Time{}
+		}
+		e.level.Store(level)
+		e.explicit = true
+	}
+	if next != nil {
+		e.next = next
+		if e.counters == nil {
+			e.counters = &handlerCounters{}
+		}
+	}
+	reg.inherit()
+	return e
+}
+
+// newRegistryEntry returns the entry with a new level, which is inherited.
+func newRegistryEntry() *registryEntry {
+	e := &registryEntry{}
+	e.level.Store(&slog.LevelVar{})
+	return e
+}
+
+// entryOf returns the entry with the given name, registering the counted entry with inherited level if there is none.
+func (reg *Registry) entryOf(name string) *registryEntry {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	e, ok := reg.entries[name]
+	if !ok {
+		e = newRegistryEntry()
+		e.counters = &handlerCounters{}
+		reg.entries[name] = e
+		reg.inherit()
+	}
+	return e
+}
+
+// inherit sets inherited levels of all entries. Lock must be held.
+func (reg *Registry) inherit() {
+	for name, e := range reg.entries {
+		if !e.explicit {
+			e.level.Load().Set(reg.parentLevel(name))
+		}
+	}
+}
+
+// parentLevel returns the level of the nearest parent with explicitly set level. Lock must be held.
+func (reg *Registry) parentLevel(name string) slog.Level {
+	for name != "" {
+		if pos := strings.LastIndexByte(name, '.'); pos >= 0 {
+			name = name[:pos]
+		} else {
+			name = ""
+		}
+		if e, ok := reg.entries[name]; ok && e.explicit {
+			return e.level.Load().Level()
+		}
+	}
+	return slog.LevelInfo
+}
+
+// hasChildren reports whether there are entries with names, starting with the given name and dot. Lock must be held.
+func (reg *Registry) hasChildren(name string) bool {
+	for child := range reg.entries {
+		if strings.HasPrefix(child, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Unregister removes the registration. The level keeps its current value.
+func (reg *Registry) Unregister(name string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if e, ok := reg.entries[name]; ok {
+		if e.timer != nil {
+			e.timer.Stop()
+			e.level.Load().Set(e.base)
+		}
+		delete(reg.entries, name)
+		reg.inherit()
+	}
+}
+
+// Level returns the level, registered under the given name.
+func (reg *Registry) Level(name string) (*slog.LevelVar, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	e, ok := reg.entries[name]
+	if !ok {
+		return nil, false
+	}
+	return e.level.Load(), true
+}
+
+// SetLevel sets the level, registered under the given name, and levels of its children, which inherit it.
+// The name may be not registered, if it is a parent of registered names, like "db" of "db.pool";
+// then it is registered without the handler. If ttl is positive, the level is overridden temporarily: the previous level is restored after ttl,
+// unless SetLevel is called again.
+func (reg *Registry) SetLevel(name string, level slog.Level, ttl time.Duration) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	e, ok := reg.entries[name]
+	if !ok {
+		if !reg.hasChildren(name) {
+			return fmt.Errorf("%w: %q", ErrNotRegistered, name)
+		}
+		e = newRegistryEntry()
+		e.level.Load().Set(reg.parentLevel(name))
+		reg.entries[name] = e
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	} else {
+		e.base, e.baseSet = e.level.Load().Level(), e.explicit
+	}
+	e.level.Load().Set(level)
+	e.explicit = true
+	e.expires = time.Time{}
+	if ttl > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(ttl, func() {
+			reg.mu.Lock()
+			defer reg.mu.Unlock()
+			if e.timer == timer { // not stopped by SetLevel or Revert, which raced with the timer
+				e.restore()
+				reg.inherit()
+			}
+		})
+		e.timer = timer
+		e.expires = time.Now().Add(ttl)
+	}
+	reg.inherit()
+	return nil
+}
+
+// restore restores the level, overridden temporarily. Lock must be held.
+func (e *registryEntry) restore() {
+	e.level.Load().Set(e.base)
+	e.explicit = e.baseSet
+	e.timer = nil
+	e.expires = time.Time{}
+}
+
+// Revert restores the level, which was temporarily overridden by [Registry.SetLevel].
+func (reg *Registry) Revert(name string) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	e, ok := reg.entries[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrNotRegistered, name)
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+		e.restore()
+		reg.inherit()
+	}
+	return nil
+}
+
+// Entries returns descriptions of registered levels, sorted by name.
+func (reg *Registry) Entries() []RegistryEntry {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	rv := make([]RegistryEntry, 0, len(reg.entries))
+	for name := range reg.entries {
+		rv = append(rv, reg.entry(name))
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Name < rv[j].Name })
+	return rv
+}
+
+// Entry returns the description of the level, registered under the given name.
+func (reg *Registry) Entry(name string) (RegistryEntry, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.entries[name]; !ok {
+		return RegistryEntry{}, false
+	}
+	return reg.entry(name), true
+}
+
+// entry describes the existing entry. Lock must be held.
+func (reg *Registry) entry(name string) RegistryEntry {
+	e := reg.entries[name]
+	rv := RegistryEntry{
+		Name:      name,
+		Level:     LevelString(e.level.Load().Level()),
+		Inherited: !e.explicit,
+	}
+	if e.timer != nil {
+		expires := e.expires
+		rv.BaseLevel = LevelString(e.base)
+		rv.ExpiresAt = &expires
+	}
+	if e.counters != nil {
+		rv.Counters = &HandlerCounters{
+			Debug:  e.counters.debug.Load(),
+			Info:   e.counters.info.Load(),
+			Warn:   e.counters.warn.Load(),
+			Error:  e.counters.error.Load(),
+			Errors: e.counters.errors.Load(),
+		}
+		if d, ok := e.next.(interface{ Dropped() uint64 }); ok {
+			rv.Counters.Dropped = d.Dropped()
+		}
+	}
+	return rv
+}
+
+// ServeHTTP returns registered levels as JSON array. The "name" query parameter selects one level.
+// PUT and POST methods set the level, given by "name" and "level" parameters, like
+// "?name=db&level=debug&ttl=10m"; the optional "ttl" parameter makes the change temporary.
+// DELETE method reverts the temporary change of the level, given by "name".
+// Implements [http.Handler] interface.
+func (reg *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name := query.Get("name")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if name == "" {
+			writeJSON(w, reg.Entries())
+			return
+		}
+	case http.MethodPut, http.MethodPost:
+		level, err := ParseLevel(query.Get("level"))
+		if err != nil {
+			http.Error(w, "invalid level", http.StatusBadRequest)
+			return
+		}
+		var ttl time.Duration
+		if s := query.Get("ttl"); s != "" {
+			if ttl, err = time.ParseDuration(s); err != nil || ttl <= 0 {
+				http.Error(w, "invalid ttl", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := reg.SetLevel(name, level, ttl); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	case http.MethodDelete:
+		if err := reg.Revert(name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	e, ok := reg.Entry(name)
+	if !ok {
+		http.Error(w, "level not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, e)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v) //nolint:errcheck,errchkjson
+}
+
+// -----------------------------------------------------------------------------
+
+// registeredHandler filters records by the registered level and counts them.
+type registeredHandler struct {
+	entry *registryEntry
+	next  slog.Handler
+}
+
+func (h *registeredHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.entry.level.Load().Level() || overrideEnabled(ctx, level)
+}
+
+func (h *registeredHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	c := h.entry.counters
+	if c == nil {
+		return h.next.Handle(ctx, r) //nolint:wrapcheck
+	}
+	switch {
+	case r.Level < slog.LevelInfo:
+		c.debug.Add(1)
+	case r.Level < slog.LevelWarn:
+		c.info.Add(1)
+	case r.Level < slog.LevelError:
+		c.warn.Add(1)
+	default:
+		c.error.Add(1)
+	}
+	if err := h.next.Handle(ctx, r); err != nil {
+		c.errors.Add(1)
+		return err //nolint:wrapcheck
+	}
+	return nil
+}
+
+func (h *registeredHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &registeredHandler{entry: h.entry, next: h.next.WithAttrs(aa)}
+}
+
+func (h *registeredHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &registeredHandler{entry: h.entry, next: h.next.WithGroup(name)}
+}
+
+func (h *registeredHandler) Flush(ctx context.Context) error {
+	return flushNext(ctx, h.next)
+}
+
+func (h *registeredHandler) Close() error {
+	return closeNext(h.next)
+}
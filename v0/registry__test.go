@@ -0,0 +1,77 @@
+package mlog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__Registry__HTTP(t *testing.T) {
+	tt := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	reg := mlog.NewRegistry()
+	logger := slog.New(reg.Register("main", nil, slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	reg.RegisterLevel("db", &slog.LevelVar{})
+	server := httptest.NewServer(reg)
+	defer server.Close()
+
+	request := func(method, query string, status int) any {
+		req, err := http.NewRequest(method, server.URL+"/?"+query, nil)
+		tt.NoError(err)
+		resp, err := http.DefaultClient.Do(req)
+		tt.NoError(err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		tt.NoError(err)
+		tt.EqualValues(status, resp.StatusCode, string(body))
+		var rv any
+		if status == http.StatusOK {
+			tt.NoError(json.Unmarshal(body, &rv))
+		}
+		return rv
+	}
+
+	logger.Debug("hidden")
+	logger.Info("shown")
+	tt.EqualValues(1, strings.Count(buf.String(), "\n"))
+
+	rv := request(http.MethodPut, "name=main&level=debug&ttl=100ms", http.StatusOK)
+	tt.EqualValues("DEBUG", JqMust(t, rv, ".level"))
+	tt.EqualValues("INFO", JqMust(t, rv, ".base_level"))
+	logger.Debug("shown temporarily")
+	tt.EqualValues(2, strings.Count(buf.String(), "\n"))
+
+	rv = request(http.MethodGet, "", http.StatusOK)
+	tt.True(Jq(t, rv, `map(.name) == ["db", "main"]`))
+	tt.True(Jq(t, rv, `.[0].counters == null and .[1].counters == {debug: 1, info: 1, warn: 0, error: 0, errors: 0}`))
+	tt.Eventually(func() bool {
+		return JqGetString(request(http.MethodGet, "name=main", http.StatusOK), ".level") == "INFO"
+	}, 5*time.Second, 10*time.Millisecond)
+	logger.Debug("hidden again")
+	tt.EqualValues(2, strings.Count(buf.String(), "\n"))
+
+	request(http.MethodPost, "name=db&level=warn", http.StatusOK)
+	rv = request(http.MethodPost, "name=db&level=error&ttl=1h", http.StatusOK)
+	tt.EqualValues("WARN", JqMust(t, rv, ".base_level"))
+	rv = request(http.MethodDelete, "name=db", http.StatusOK)
+	tt.EqualValues("WARN", JqMust(t, rv, ".level"))
+	tt.True(Jq(t, rv, `has("expires_at") | not`))
+	rv = request(http.MethodPut, "name=db&level=trace", http.StatusOK)
+	tt.EqualValues("TRACE", JqMust(t, rv, ".level"))
+
+	request(http.MethodPut, "name=cache&level=debug", http.StatusNotFound)
+	request(http.MethodPut, "name=db&level=loud", http.StatusBadRequest)
+	request(http.MethodPut, "name=db&level=info&ttl=-1s", http.StatusBadRequest)
+	request(http.MethodGet, "name=cache", http.StatusNotFound)
+	request(http.MethodPatch, "", http.StatusMethodNotAllowed)
+}
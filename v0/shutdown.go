@@ -0,0 +1,205 @@
+package mlog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Flusher is implemented by handlers and writers, which buffer records, like [OTLPHandler] or [NetWriter].
+// Flush sends buffered records and waits for the result or ctx cancellation.
+// [MultipleHandler] and mlog wrappers, like [LevelHandler], forward Flush to their next handlers.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Closer is implemented by handlers and writers, which own resources, like connections or background goroutines.
+// Close flushes buffered records and releases resources.
+// [MultipleHandler] and mlog wrappers, like [LevelHandler], forward Close to their next handlers.
+type Closer interface {
+	Close() error
+}
+
+var shutdownState struct { //nolint:gochecknoglobals
+	mu    sync.Mutex
+	items []any
+}
+
+// OnShutdown registers handlers or writers, which are flushed and closed by [Shutdown],
+// if they implement [Flusher] or [Closer]. They are closed in the reverse order of registration,
+// so a writer should be registered before handlers, which write to it.
+func OnShutdown(items ...any) {
+	shutdownState.mu.Lock()
+	defer shutdownState.mu.Unlock()
+	shutdownState.items = append(shutdownState.items, items...)
+}
+
+// Shutdown flushes and closes the handler of [slog.Default] and handlers, registered by [OnShutdown].
+// Each of them is flushed and closed once, even if it is registered several times.
+// If ctx has no deadline, [DefaultCloseTimeout] is used; Close calls, which do not finish in time, are abandoned.
+// Shutdown is intended to be called once, before the program exits:
+//
+//	defer mlog.Shutdown(context.Background())
+func Shutdown(ctx context.Context) error {
+	return shutdown(ctx, slog.Default().Handler())
+}
+
+// shutdown flushes and closes the given handlers, then registered ones.
+func shutdown(ctx context.Context, handlers ...any) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultCloseTimeout)
+		defer cancel()
+	}
+	shutdownState.mu.Lock()
+	items := shutdownState.items
+	shutdownState.items = nil
+	shutdownState.mu.Unlock()
+	for i := len(items) - 1; i >= 0; i-- {
+		handlers = append(handlers, items[i])
+	}
+
+	var errs []error
+	done := make([]any, 0, len(handlers))
+loop:
+	for _, h := range handlers {
+		for _, d := range done {
+			if sameItem(h, d) {
+				continue loop
+			}
+		}
+		done = append(done, h)
+		// items may share the queue with one, which is already closed, like handlers derived by WithAttrs
+		if err := flushItem(ctx, h); err != nil && !errors.Is(err, ErrClosed) {
+			errs = append(errs, err)
+		}
+		if err := closeItem(ctx, h); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sameItem reports whether a and b are the same pointer; values of other kinds may be not comparable.
+func sameItem(a, b any) bool {
+	switch a.(type) {
+	case Flusher, Closer:
+	default:
+		return false
+	}
+	defer func() { recover() }() //nolint:errcheck
+	return a == b
+}
+
+// flushItem calls Flush of v, if it implements [Flusher].
+func flushItem(ctx context.Context, v any) error {
+	if f, ok := v.(Flusher); ok {
+		return f.Flush(ctx) //nolint:wrapcheck
+	}
+	return nil
+}
+
+// closeItem calls Close of v, if it implements [Closer], and waits for it until ctx is done.
+func closeItem(ctx context.Context, v any) error {
+	c, ok := v.(Closer)
+	if !ok {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Close()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck
+	}
+}
+
+// flushNext calls Flush of each handler, implementing [Flusher], and joins errors.
+func flushNext(ctx context.Context, handlers ...slog.Handler) error {
+	var errs []error
+	for _, h := range handlers {
+		if err := flushItem(ctx, h); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeNext calls Close of each handler, implementing [Closer], and joins errors.
+func closeNext(handlers ...slog.Handler) error {
+	var errs []error
+	for _, h := range handlers {
+		if c, ok := h.(Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// -----------------------------------------------------------------------------
+
+// ShutdownOptions are options for [ShutdownOnSignal].
+type ShutdownOptions struct {
+	// Signals trigger the shutdown. If empty, SIGTERM and SIGINT are used.
+	Signals []os.Signal
+	// Timeout limits the time of [Shutdown]. If zero, [DefaultCloseTimeout] is used.
+	Timeout time.Duration
+	// Exit is called after [Shutdown] with the code 128+signal number, like the shell reports
+	// the process, killed by the signal. If nil, [os.Exit] is used.
+	Exit func(code int)
+}
+
+// ShutdownOnSignal calls [Shutdown] and exits when one of the signals is received, so records,
+// buffered by handlers, are not lost when the process is terminated. It is intended for programs
+// without own graceful shutdown; otherwise call [Shutdown] at the end of it.
+// The returned function stops waiting for signals.
+// If opts is nil, the default options are used.
+func ShutdownOnSignal(opts *ShutdownOptions) (stop func()) {
+	o := ShutdownOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if len(o.Signals) == 0 {
+		o.Signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultCloseTimeout
+	}
+	if o.Exit == nil {
+		o.Exit = os.Exit
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, o.Signals...)
+	quit := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			ctx, cancel := context.WithTimeout(context.Background(), o.Timeout)
+			_ = Shutdown(ctx)
+			cancel()
+			code := 1
+			if s, ok := sig.(syscall.Signal); ok {
+				code = 128 + int(s) //nolint:gomnd
+			}
+			o.Exit(code)
+		case <-quit:
+			signal.Stop(signals)
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(quit) })
+	}
+}
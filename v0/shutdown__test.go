@@ -0,0 +1,111 @@
+package mlog_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+// blockingCloser is a handler, whose Close waits for release.
+type blockingCloser struct {
+	slog.Handler
+	release chan struct{}
+}
+
+func (h *blockingCloser) Close() error {
+	<-h.release
+	return nil
+}
+
+func Test__Shutdown__Forward(t *testing.T) {
+	tt := assert.New(t)
+
+	discard := slog.NewJSONHandler(io.Discard, nil)
+	first := &closableHandler{Handler: discard}
+	second := &closableHandler{Handler: discard}
+	router, err := mlog.NewLevelRouter("info", first)
+	tt.NoError(err)
+	metrics := mlog.NewMetricsHandler(nil)
+	h := mlog.NewSwapHandler(mlog.NewMultipleHandler(nil,
+		mlog.NewLevelHandler(nil, mlog.NewContextHandler(router, nil)),
+		mlog.NewFilterHandler(metrics.Wrap("second", mlog.NewRegistry().Register("second", nil, second)), nil),
+		discard,
+	))
+
+	tt.NoError(h.Flush(context.Background()))
+	tt.NoError(h.WithAttrs([]slog.Attr{slog.Int("a", 1)}).(mlog.Closer).Close())
+	tt.EqualValues([]string{"flush", "close"}, first.calls)
+	tt.EqualValues([]string{"flush", "close"}, second.calls)
+}
+
+func Test__Shutdown__Registered(t *testing.T) {
+	tt := assert.New(t)
+
+	discard := slog.NewJSONHandler(io.Discard, nil)
+	first := &closableHandler{Handler: discard}
+	second := &closableHandler{Handler: discard}
+	mlog.OnShutdown(first, discard, second, first)
+
+	tt.NoError(mlog.Shutdown(context.Background()))
+	tt.EqualValues([]string{"flush", "close"}, first.calls)
+	tt.EqualValues([]string{"flush", "close"}, second.calls)
+
+	tt.NoError(mlog.Shutdown(context.Background()))
+	tt.Len(first.calls, 2)
+
+	blocking := &blockingCloser{Handler: discard, release: make(chan struct{})}
+	defer close(blocking.release)
+	mlog.OnShutdown(blocking)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	tt.ErrorIs(mlog.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func Test__Shutdown__SharedQueue(t *testing.T) {
+	tt := assert.New(t)
+
+	c := newCollector(t)
+	h := mlog.NewOTLPHandler(&mlog.OTLPHandlerOptions{Endpoint: c.server.URL})
+	derived := h.WithAttrs([]slog.Attr{slog.Int("a", 1)}) // shares the queue with h
+	mlog.OnShutdown(h, derived)
+
+	slog.New(derived).Info("queued")
+	tt.NoError(mlog.Shutdown(context.Background()))
+	requests, _ := c.received()
+	tt.Len(requests, 1)
+}
+
+func Test__Shutdown__Signal(t *testing.T) {
+	tt := assert.New(t)
+
+	h := &closableHandler{Handler: slog.NewJSONHandler(io.Discard, nil)}
+	mlog.OnShutdown(h)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	code := 0
+	stop := mlog.ShutdownOnSignal(&mlog.ShutdownOptions{
+		Signals: []os.Signal{syscall.SIGTERM},
+		Exit: func(c int) {
+			code = c
+			wg.Done()
+		},
+	})
+	defer stop()
+
+	p, err := os.FindProcess(os.Getpid())
+	tt.NoError(err)
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Skip("signals are not supported:", err)
+	}
+	wg.Wait()
+	tt.EqualValues(128+int(syscall.SIGTERM), code)
+	tt.EqualValues([]string{"flush", "close"}, h.calls)
+}
@@ -0,0 +1,61 @@
+package mlog
+
+import (
+	"encoding/binary"
+)
+
+// snappyEncode compresses src into the snappy block format, as Loki push API requires for protobuf requests.
+// It is a simple greedy compressor, finding 4-byte matches by hash, without the framing format.
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(make([]byte, 0, len(src)/2+16), uint64(len(src))) //nolint:gomnd
+	var table [1 << snappyHashBits]int32                                          // position+1 of the last occurrence of the hash
+	lit := 0                                                                      // start of the pending literal
+	for i := 0; i+4 <= len(src); {
+		word := binary.LittleEndian.Uint32(src[i:])
+		h := (word * 0x1e35a7bd) >> (32 - snappyHashBits) //nolint:gomnd
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if candidate < 0 || i-candidate > snappyMaxOffset || binary.LittleEndian.Uint32(src[candidate:]) != word {
+			i++
+			continue
+		}
+		dst = snappyAppendLiteral(dst, src[lit:i])
+		n := 4
+		for i+n < len(src) && src[candidate+n] == src[i+n] {
+			n++
+		}
+		dst = snappyAppendCopy(dst, i-candidate, n)
+		i += n
+		lit = i
+	}
+	return snappyAppendLiteral(dst, src[lit:])
+}
+
+func snappyAppendLiteral(dst, lit []byte) []byte {
+	n := len(lit) - 1
+	switch {
+	case n < 0:
+		return dst
+	case n < 60: //nolint:gomnd
+		dst = append(dst, byte(n<<2))
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// snappyAppendCopy appends copy elements with 2-byte offset, each of them copies up to 64 bytes.
+func snappyAppendCopy(dst []byte, offset, n int) []byte {
+	for n > 0 {
+		l := min(n, snappyMaxCopy)
+		dst = append(dst, byte((l-1)<<2|2), byte(offset), byte(offset>>8))
+		n -= l
+	}
+	return dst
+}
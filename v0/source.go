@@ -0,0 +1,143 @@
+package mlog
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SourceFormat defines how the source code position is shown in the plain text part of the log line.
+type SourceFormat int
+
+const (
+	// SourceBaseName shows file name without directory, like "handler.go:12". It is a default format.
+	SourceBaseName SourceFormat = iota
+	// SourceRelativePath shows file path relative to the root directory of the main module,
+	// like "cmd/api/handler.go:12". Files of other modules are shown with the module path,
+	// like "github.com/acme/lib/db/handler.go:12". The module root is found by its go.mod file;
+	// binaries, built with -trimpath, have such paths already.
+	SourceRelativePath
+	// SourceFullPath shows full file path, like "/src/app/internal/db/handler.go:12".
+	SourceFullPath
+	// SourceFunction shows fully qualified function name, like "github.com/acme/app/internal/db.(*Pool).Get:12".
+	SourceFunction
+	// SourcePackageFunction shows function name with package name only, like "db.(*Pool).Get:12".
+	SourcePackageFunction
+)
+
+var mainModulePath = sync.OnceValue(func() string { //nolint:gochecknoglobals
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		return bi.Main.Path
+	}
+	return ""
+})
+
+// moduleRoot is the root directory of the module and the module path, read from its go.mod file.
+type moduleRoot struct {
+	dir, path string
+}
+
+var moduleRoots sync.Map //nolint:gochecknoglobals // directory -> *moduleRoot, nil if there is no go.mod
+
+// findModule returns the module, which contains the directory.
+func findModule(dir string) *moduleRoot {
+	if v, ok := moduleRoots.Load(dir); ok {
+		return v.(*moduleRoot) //nolint:forcetypeassert
+	}
+	var rv *moduleRoot
+	if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+		rv = &moduleRoot{dir: dir, path: moduleDirective(data)}
+	} else if parent := filepath.Dir(dir); parent != dir {
+		rv = findModule(parent)
+	}
+	moduleRoots.Store(dir, rv)
+	return rv
+}
+
+// moduleDirective returns the module path from the go.mod file content.
+func moduleDirective(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(strings.TrimSpace(line), "module"); ok {
+			path = strings.TrimSpace(path)
+			if unquoted, err := strconv.Unquote(path); err == nil {
+				path = unquoted
+			}
+			return path
+		}
+	}
+	return ""
+}
+
+// relativeSource returns the file path relative to the main module root, see [SourceRelativePath].
+func relativeSource(s *slog.Source) string {
+	mod := mainModulePath()
+	if !filepath.IsAbs(s.File) { // built with -trimpath, the path starts with the module path
+		if rel, ok := strings.CutPrefix(s.File, mod+"/"); ok && mod != "" {
+			return rel
+		}
+		return s.File
+	}
+	root := findModule(filepath.Dir(s.File))
+	if root == nil || root.path == "" {
+		return filepath.Base(s.File)
+	}
+	rel, err := filepath.Rel(root.dir, s.File)
+	if err != nil {
+		return filepath.Base(s.File)
+	}
+	rel = filepath.ToSlash(rel)
+	if root.path == mod {
+		return rel
+	}
+	return root.path + "/" + rel
+}
+
+// FormatSource returns "location:line" string representation of the source code position in the given format.
+func FormatSource(s *slog.Source, f SourceFormat) string {
+	return fmt.Sprintf("%s:%d", sourceLocation(s, f), s.Line)
+}
+
+func sourceLocation(s *slog.Source, f SourceFormat) string {
+	switch f {
+	case SourceRelativePath:
+		return relativeSource(s)
+	case SourceFullPath:
+		return s.File
+	case SourceFunction:
+		return s.Function
+	case SourcePackageFunction:
+		if pos := strings.LastIndexByte(s.Function, '/'); pos >= 0 {
+			return s.Function[pos+1:]
+		}
+		return s.Function
+	case SourceBaseName:
+	}
+	return filepath.Base(s.File)
+}
+
+// functionPackage returns import path of the package from the fully qualified function name,
+// "github.com/acme/db.(*Pool).Get" -> "github.com/acme/db".
+func functionPackage(fn string) string {
+	slash := strings.LastIndexByte(fn, '/')
+	dot := strings.IndexByte(fn[slash+1:], '.')
+	if dot < 0 {
+		return fn
+	}
+	return fn[:slash+1+dot]
+}
+
+var hostname = sync.OnceValue(func() string { //nolint:gochecknoglobals
+	h, _ := os.Hostname()
+	return h
+})
+
+// appendHyperlink appends text, wrapped by OSC 8 terminal escape sequence,
+// which makes it a link to the source file.
+func appendHyperlink(buf []byte, s *slog.Source, text string) []byte {
+	return fmt.Appendf(buf, "\x1b]8;;file://%s%s#%d\x1b\\%s\x1b]8;;\x1b\\", hostname(), filepath.ToSlash(s.File), s.Line, text)
+}
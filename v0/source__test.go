@@ -0,0 +1,89 @@
+package mlog_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func sourceField(t *testing.T, line string) string {
+	t.Helper()
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		t.Fatalf("too short log line: %s", line)
+	}
+	return strings.Trim(fields[2], "[]")
+}
+
+func Test__Source__Formats(t *testing.T) {
+	tt := assert.New(t)
+
+	testCases := map[mlog.SourceFormat]string{
+		mlog.SourceBaseName:        "source__test.go:",
+		mlog.SourceRelativePath:    "v0/source__test.go:",
+		mlog.SourceFunction:        "github.com/xenolog/mlog/v0_test.Test__Source__Formats:",
+		mlog.SourcePackageFunction: "v0_test.Test__Source__Formats:",
+	}
+	for format, prefix := range testCases {
+		svWriter := &bytes.Buffer{}
+		logger := slog.New(mlog.NewHumanReadableHandler(svWriter, &mlog.HumanReadableHandlerOptions{AddSource: true, SourceFormat: format}))
+		logger.Info("msg")
+		tt.True(strings.HasPrefix(sourceField(t, svWriter.String()), prefix), "%d: %s", format, svWriter.String())
+	}
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, &mlog.HumanReadableHandlerOptions{AddSource: true, SourceFormat: mlog.SourceFullPath}))
+	logger.Info("msg")
+	tt.True(strings.HasPrefix(sourceField(t, svWriter.String()), "/"))
+	tt.Contains(sourceField(t, svWriter.String()), "/v0/source__test.go:")
+}
+
+func Test__Source__RelativePath(t *testing.T) {
+	tt := assert.New(t)
+
+	dir := t.TempDir()
+	tt.NoError(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0o600))
+	for _, name := range []string{"cmd/a/handler.go", "cmd/b/handler.go", "main.go"} {
+		s := &slog.Source{Function: "main.main", File: filepath.Join(dir, filepath.FromSlash(name)), Line: 7}
+		tt.EqualValues("example.com/app/"+name+":7", mlog.FormatSource(s, mlog.SourceRelativePath))
+	}
+
+	s := &slog.Source{Function: "main.main", File: "github.com/xenolog/mlog/cmd/a/handler.go", Line: 7}
+	tt.EqualValues("cmd/a/handler.go:7", mlog.FormatSource(s, mlog.SourceRelativePath), "built with -trimpath")
+}
+
+func Test__Source__Hyperlinks(t *testing.T) {
+	tt := assert.New(t)
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, &mlog.HumanReadableHandlerOptions{AddSource: true, SourceHyperlinks: true}))
+	logger.Info("msg")
+	tt.Contains(svWriter.String(), "\x1b]8;;file://")
+	tt.Contains(svWriter.String(), "/v0/source__test.go#")
+	tt.Contains(svWriter.String(), "\x1b\\source__test.go:")
+	tt.Contains(svWriter.String(), "\x1b]8;;\x1b\\]  msg")
+}
+
+func logWrapper(logger *slog.Logger, msg string) {
+	mlog.LogSkip(context.Background(), logger, 1, slog.LevelInfo, msg)
+}
+
+func Test__Source__CallerSkip(t *testing.T) {
+	tt := assert.New(t)
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, &mlog.HumanReadableHandlerOptions{AddSource: true, SourceFormat: mlog.SourcePackageFunction}))
+	logWrapper(logger, "msg")
+	tt.True(strings.HasPrefix(sourceField(t, svWriter.String()), "v0_test.Test__Source__CallerSkip:"), svWriter.String())
+
+	r := mlog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)
+	tt.True(strings.HasSuffix(mlog.DecodeSource(r.PC).Function, t.Name()))
+}
@@ -0,0 +1,135 @@
+package mlog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// SwapHandler is a [slog.Handler] wrapper, whose next handler can be replaced at runtime,
+// for example after reload of the logging configuration. Handlers, derived by WithAttrs and WithGroup,
+// follow the replacement: their attributes and groups are applied to the new handler.
+type SwapHandler struct {
+	shared *swapShared
+	ops    []swapOp
+	cache  atomic.Pointer[swapCache]
+}
+
+// swapShared is the current handler, shared by all derived handlers.
+type swapShared struct {
+	mu      sync.RWMutex // Handle holds read lock, so Swap waits for records in progress
+	handler slog.Handler
+	gen     uint64
+}
+
+// swapOp is WithAttrs (if attrs is not nil) or WithGroup call.
+type swapOp struct {
+	attrs []slog.Attr
+	group string
+}
+
+// swapCache is the current handler of the generation with applied attributes and groups.
+type swapCache struct {
+	gen     uint64
+	handler slog.Handler
+}
+
+// NewSwapHandler creates a SwapHandler with the given next handler.
+func NewSwapHandler(next slog.Handler) *SwapHandler {
+	return &SwapHandler{
+		shared: &swapShared{handler: next},
+	}
+}
+
+// Swap replaces the next handler of h and all handlers, derived from it, and returns the previous one.
+// It waits for records, which are being handled by the previous handler, so the previous handler
+// may be flushed and closed right after Swap without losing records.
+func (h *SwapHandler) Swap(next slog.Handler) slog.Handler {
+	h.shared.mu.Lock()
+	defer h.shared.mu.Unlock()
+	prev := h.shared.handler
+	h.shared.handler = next
+	h.shared.gen++
+	return prev
+}
+
+// Handler returns the current next handler.
+func (h *SwapHandler) Handler() slog.Handler {
+	h.shared.mu.RLock()
+	defer h.shared.mu.RUnlock()
+	return h.shared.handler
+}
+
+// current returns the next handler with applied attributes and groups. Read lock must be held.
+func (h *SwapHandler) current() slog.Handler {
+	if len(h.ops) == 0 {
+		return h.shared.handler
+	}
+	if c := h.cache.Load(); c != nil && c.gen == h.shared.gen {
+		return c.handler
+	}
+	next := h.shared.handler
+	for _, op := range h.ops {
+		if op.attrs != nil {
+			next = next.WithAttrs(op.attrs)
+		} else {
+			next = next.WithGroup(op.group)
+		}
+	}
+	h.cache.Store(&swapCache{gen: h.shared.gen, handler: next})
+	return next
+}
+
+// Enabled reports whether the current next handler handles records at the given level.
+// Implements [slog.Handler] interface.
+func (h *SwapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	h.shared.mu.RLock()
+	defer h.shared.mu.RUnlock()
+	return h.current().Enabled(ctx, level)
+}
+
+// Handle passes the record to the current next handler.
+// Implements [slog.Handler] interface.
+func (h *SwapHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	h.shared.mu.RLock()
+	defer h.shared.mu.RUnlock()
+	return h.current().Handle(ctx, r) //nolint:wrapcheck
+}
+
+// WithAttrs returns a new SwapHandler whose next handler has given attributes.
+// Implements [slog.Handler] interface.
+func (h *SwapHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	if len(aa) == 0 {
+		return h
+	}
+	return h.with(swapOp{attrs: aa})
+}
+
+// WithGroup returns a new SwapHandler whose next handler has the given group.
+// Implements [slog.Handler] interface.
+func (h *SwapHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(swapOp{group: name})
+}
+
+func (h *SwapHandler) with(op swapOp) *SwapHandler {
+	return &SwapHandler{
+		shared: h.shared,
+		ops:    append(slices.Clip(h.ops), op),
+	}
+}
+
+// Flush flushes the current next handler, if it implements [Flusher].
+func (h *SwapHandler) Flush(ctx context.Context) error {
+	return flushNext(ctx, h.Handler())
+}
+
+// Close closes the current next handler, if it implements [Closer].
+// Handlers, replaced by Swap, are not closed, it is up to the caller of Swap.
+func (h *SwapHandler) Close() error {
+	return closeNext(h.Handler())
+}
@@ -0,0 +1,77 @@
+package mlog_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+// syncBuffer is a bytes.Buffer, safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func Test__SwapHandler__Derived(t *testing.T) {
+	tt := assert.New(t)
+
+	first, second := &bytes.Buffer{}, &bytes.Buffer{}
+	swap := mlog.NewSwapHandler(mlog.NewHumanReadableHandler(first, nil))
+	logger := slog.New(swap).With("a", 1).WithGroup("g")
+
+	logger.Info("one", "b", 2)
+	prev := swap.Swap(slog.NewJSONHandler(second, nil))
+	logger.Info("two", "b", 3)
+
+	tt.IsType(&mlog.HumanReadableHandler{}, prev)
+	tt.Contains(first.String(), `one  ATTRS={"a":1,"g":{"b":2}}`)
+	tt.NotContains(first.String(), "two")
+	tt.Contains(second.String(), `"msg":"two","a":1,"g":{"b":3}}`)
+}
+
+func Test__SwapHandler__Concurrent(t *testing.T) {
+	tt := assert.New(t)
+
+	const workers, records = 4, 500
+	buffers := []*syncBuffer{{}, {}, {}}
+	swap := mlog.NewSwapHandler(mlog.NewHumanReadableHandler(buffers[0], nil))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger := slog.New(swap).With("worker", i)
+			for j := 0; j < records; j++ {
+				logger.Info("message")
+			}
+		}(i)
+	}
+	for _, buf := range buffers[1:] {
+		swap.Swap(mlog.NewHumanReadableHandler(buf, nil))
+	}
+	wg.Wait()
+
+	total := 0
+	for _, buf := range buffers {
+		total += strings.Count(buf.String(), "\n")
+	}
+	tt.EqualValues(workers*records, total)
+}
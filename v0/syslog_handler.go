@@ -0,0 +1,391 @@
+package mlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Severity is a syslog message severity, as defined by RFC 5424.
+type Severity int
+
+const (
+	SeverityEmergency Severity = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+var severityNames = [...]string{ //nolint:gochecknoglobals
+	"EMERGENCY", "ALERT", "CRITICAL", "ERROR", "WARNING", "NOTICE", "INFO", "DEBUG",
+}
+
+// String returns the upper-case severity name, like "WARNING".
+// These names are used by Google Cloud Logging as well.
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return "DEFAULT"
+	}
+	return severityNames[s]
+}
+
+// LevelToSeverity maps [slog.Level] to syslog severity. Standard levels are mapped to
+// the severities with the same names, custom levels between them are mapped as follows:
+// Info+2 and above to Notice, Error+4 and above to Critical, Error+8 to Alert, Error+12 to Emergency.
+func LevelToSeverity(l slog.Level) Severity {
+	switch {
+	case l < slog.LevelInfo:
+		return SeverityDebug
+	case l < slog.LevelInfo+2:
+		return SeverityInfo
+	case l < slog.LevelWarn:
+		return SeverityNotice
+	case l < slog.LevelError:
+		return SeverityWarning
+	case l < slog.LevelError+4:
+		return SeverityError
+	case l < slog.LevelError+8:
+		return SeverityCritical
+	case l < slog.LevelError+12:
+		return SeverityAlert
+	}
+	return SeverityEmergency
+}
+
+// Facility is a syslog facility code, as defined by RFC 5424.
+type Facility int
+
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFtp
+	FacilityLocal0 Facility = iota + 4 //nolint:gomnd // codes 12-15 are reserved for system use
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// SyslogFormat is a syslog message format.
+type SyslogFormat int
+
+const (
+	// SyslogRFC5424 is a modern syslog format with structured data. It is a default format.
+	SyslogRFC5424 SyslogFormat = iota
+	// SyslogRFC3164 is a legacy BSD syslog format.
+	SyslogRFC3164
+)
+
+type SyslogHandlerOptions struct {
+	// Network is "unixgram", "unix", "udp" or "tcp". If empty, the local syslog daemon is used
+	// and Addr is ignored: well-known sockets like /dev/log are tried one by one.
+	Network string
+	// Addr is the syslog server address or unix socket path.
+	Addr string
+	// DialTimeout limits time to connect to the syslog server. If zero, [DefaultDialTimeout] is used.
+	DialTimeout time.Duration
+
+	// Format is a syslog message format. Default is [SyslogRFC5424].
+	Format SyslogFormat
+	// Facility of the messages. Default (and used instead of [FacilityKern]) is [FacilityUser].
+	Facility Facility
+	// Hostname of the messages. If empty, [os.Hostname] is used.
+	Hostname string
+	// AppName of the messages (TAG in RFC 3164). If empty, the executable name is used.
+	AppName string
+
+	// StructuredData causes the handler to encode attributes as RFC 5424 structured data element.
+	// Otherwise, attributes are written at the end of the message as a JSON block, like [HumanReadableHandler] does.
+	// It is ignored for [SyslogRFC3164] format.
+	StructuredData bool
+	// SDID is a structured data element ID. If empty, [DefaultSyslogSDID] is used.
+	SDID string
+
+	// AddSource causes the handler to add a "source" attribute with the source code position of the log statement.
+	AddSource bool
+
+	// SeverityMapper maps record level to syslog severity. If nil, [LevelToSeverity] is used.
+	SeverityMapper func(slog.Level) Severity
+
+	// MaxAttrDepth and MaxAttrSize limit attribute values, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to log.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelInfo].
+	Level slog.Leveler
+}
+
+// SyslogHandler is a [slog.Handler] that sends Records to the syslog daemon or remote syslog server
+// in RFC 5424 or RFC 3164 format. Connection is established at the first record and re-established
+// after write errors. Messages over stream connections are framed by octet counting (RFC 6587), except local
+// unix stream sockets, like /dev/log, where syslog daemons expect messages terminated by LF.
+type SyslogHandler struct {
+	opts  SyslogHandlerOptions
+	state handlerState
+	conn  *lazyConn
+}
+
+// NewSyslogHandler creates a SyslogHandler, using the given options.
+// If opts is nil, the default options are used, i.e. messages are sent to the local syslog daemon.
+func NewSyslogHandler(opts *SyslogHandlerOptions) *SyslogHandler {
+	h := &SyslogHandler{}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	if h.opts.Facility == FacilityKern { // user processes can't log as kernel
+		h.opts.Facility = FacilityUser
+	}
+	if h.opts.Hostname == "" {
+		h.opts.Hostname = hostname()
+	}
+	if h.opts.AppName == "" {
+		h.opts.AppName = filepath.Base(os.Args[0])
+	}
+	if h.opts.SDID == "" {
+		h.opts.SDID = DefaultSyslogSDID
+	}
+	if h.opts.SeverityMapper == nil {
+		h.opts.SeverityMapper = LevelToSeverity
+	}
+	if h.opts.DialTimeout == 0 {
+		h.opts.DialTimeout = DefaultDialTimeout
+	}
+	h.state = newHandlerState(newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize))
+	if h.opts.Network != "" {
+		h.conn = newLazyConn(h.opts.Network, h.opts.Addr, h.opts.DialTimeout)
+	} else {
+		h.conn = &lazyConn{dial: func() (net.Conn, error) {
+			return dialLocalSyslog(h.opts.DialTimeout)
+		}}
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *SyslogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle formats the Record as syslog message and sends it.
+// Implements [slog.Handler] interface.
+func (h *SyslogHandler) Handle(_ context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
+	attrs, _ := h.state.tree(&r)
+	if h.opts.AddSource && r.PC != 0 {
+		attrs[slog.SourceKey] = DecodeSource(r.PC)
+	}
+
+	var buf []byte
+	if h.opts.Format == SyslogRFC3164 {
+		buf = h.appendRFC3164(make([]byte, 0, LogLineBuffSize), &r, attrs)
+	} else {
+		buf = h.appendRFC5424(make([]byte, 0, LogLineBuffSize), &r, attrs)
+	}
+	err = h.conn.write(func(conn net.Conn) error {
+		var err error
+		switch {
+		case conn.LocalAddr().Network() == "unix":
+			_, err = conn.Write(appendLineFramed(nil, buf))
+		case isStreamConn(conn):
+			_, err = fmt.Fprintf(conn, "%d %s", len(buf), buf)
+		default:
+			_, err = conn.Write(buf)
+		}
+		return err //nolint:wrapcheck
+	})
+	if err != nil {
+		return errors.Join(Error, err)
+	}
+	return nil
+}
+
+func (h *SyslogHandler) priority(l slog.Level) int {
+	return int(h.opts.Facility)*8 + int(h.opts.SeverityMapper(l)) //nolint:gomnd
+}
+
+func (h *SyslogHandler) appendRFC5424(buf []byte, r *slog.Record, attrs jsonTree) []byte {
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	buf = fmt.Appendf(buf, "<%d>1 %s %s %s %d - ",
+		h.priority(r.Level),
+		t.Format(syslogTimeFormat),
+		syslogHeaderField(h.opts.Hostname),
+		syslogHeaderField(h.opts.AppName),
+		os.Getpid(),
+	)
+	if h.opts.StructuredData && len(attrs) != 0 {
+		buf = appendStructuredData(buf, h.opts.SDID, attrs)
+		buf = append(buf, ' ')
+		return append(buf, r.Message...)
+	}
+	buf = append(buf, "- "...)
+	return appendJSONAttrs(append(buf, r.Message...), attrs)
+}
+
+func (h *SyslogHandler) appendRFC3164(buf []byte, r *slog.Record, attrs jsonTree) []byte {
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	buf = fmt.Appendf(buf, "<%d>%s %s %s[%d]: ",
+		h.priority(r.Level),
+		t.Format(time.Stamp),
+		syslogHeaderField(h.opts.Hostname),
+		syslogHeaderField(h.opts.AppName),
+		os.Getpid(),
+	)
+	return appendJSONAttrs(append(buf, r.Message...), attrs)
+}
+
+// WithAttrs returns a new SyslogHandler whose attributes consists of h's attributes followed by attrs.
+// Implements [slog.Handler] interface.
+func (h *SyslogHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &SyslogHandler{
+		opts:  h.opts,
+		state: h.state.withAttrs(aa),
+		conn:  h.conn,
+	}
+}
+
+// WithGroup returns a new SyslogHandler with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *SyslogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SyslogHandler{
+		opts:  h.opts,
+		state: h.state.withGroup(name),
+		conn:  h.conn,
+	}
+}
+
+// Close closes the connection to the syslog server.
+// The connection is shared by all handlers, derived by WithAttrs and WithGroup.
+func (h *SyslogHandler) Close() error {
+	return h.conn.close()
+}
+
+// -----------------------------------------------------------------------------
+
+// appendJSONAttrs appends attributes as JSON block with [AttrsJSONprefix], like [HumanReadableHandler] does.
+func appendJSONAttrs(buf []byte, attrs jsonTree) []byte {
+	if len(attrs) == 0 {
+		return buf
+	}
+	attrsJSON, err := json.Marshal(attrs)
+	if err != nil {
+		return append(buf, "  slogERR: "+err.Error()...)
+	}
+	buf = append(buf, "  "+AttrsJSONprefix...)
+	return append(buf, attrsJSON...)
+}
+
+// appendStructuredData appends attributes as one RFC 5424 SD-ELEMENT,
+// names of nested attributes are joined by dot.
+func appendStructuredData(buf []byte, sdID string, attrs jsonTree) []byte {
+	buf = append(buf, '[')
+	buf = append(buf, sdName(sdID)...)
+	flattenTree(attrs, "", ".", func(key string, value any) {
+		buf = append(buf, ' ')
+		buf = append(buf, sdName(key)...)
+		buf = append(buf, `="`...)
+		buf = append(buf, sdValueReplacer.Replace(formatScalar(value))...)
+		buf = append(buf, '"')
+	})
+	return append(buf, ']')
+}
+
+var sdValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`) //nolint:gochecknoglobals
+
+// sdName returns a valid RFC 5424 SD-NAME: up to 32 printable ASCII characters except '=', ' ', ']', '"'.
+func sdName(s string) string {
+	rv := []byte(s)
+	for i := range rv {
+		if rv[i] <= ' ' || rv[i] > '~' || rv[i] == '=' || rv[i] == ']' || rv[i] == '"' {
+			rv[i] = '_'
+		}
+	}
+	if len(rv) > maxSDNameLength {
+		rv = rv[:maxSDNameLength]
+	}
+	if len(rv) == 0 {
+		return "_"
+	}
+	return string(rv)
+}
+
+// syslogHeaderField returns printable ASCII string without spaces, or NILVALUE if empty.
+func syslogHeaderField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// appendLineFramed appends the message with non-transparent framing (RFC 6587): terminated by LF,
+// newlines inside the message are escaped as "#012", like syslog daemons escape control characters.
+func appendLineFramed(buf, msg []byte) []byte {
+	buf = append(buf, bytes.ReplaceAll(msg, []byte("\n"), []byte("#012"))...)
+	return append(buf, '\n')
+}
+
+// -----------------------------------------------------------------------------
+
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"} //nolint:gochecknoglobals
+
+// dialLocalSyslog connects to the local syslog daemon, trying well-known sockets one by one.
+func dialLocalSyslog(timeout time.Duration) (net.Conn, error) {
+	var errs []error
+	for _, path := range localSyslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.DialTimeout(network, path, timeout)
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
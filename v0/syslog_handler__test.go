@@ -0,0 +1,206 @@
+package mlog_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__SyslogHandler__LevelToSeverity(t *testing.T) {
+	tt := assert.New(t)
+
+	tt.EqualValues(mlog.SeverityDebug, mlog.LevelToSeverity(slog.LevelDebug))
+	tt.EqualValues(mlog.SeverityDebug, mlog.LevelToSeverity(slog.LevelDebug-4))
+	tt.EqualValues(mlog.SeverityInfo, mlog.LevelToSeverity(slog.LevelInfo))
+	tt.EqualValues(mlog.SeverityNotice, mlog.LevelToSeverity(slog.LevelInfo+2))
+	tt.EqualValues(mlog.SeverityWarning, mlog.LevelToSeverity(slog.LevelWarn))
+	tt.EqualValues(mlog.SeverityError, mlog.LevelToSeverity(slog.LevelError))
+	tt.EqualValues(mlog.SeverityCritical, mlog.LevelToSeverity(slog.LevelError+4))
+	tt.EqualValues(mlog.SeverityAlert, mlog.LevelToSeverity(slog.LevelError+8))
+	tt.EqualValues(mlog.SeverityEmergency, mlog.LevelToSeverity(slog.LevelError+100))
+}
+
+func Test__SyslogHandler__UDP_RFC5424_StructuredData(t *testing.T) {
+	tt := assert.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	tt.NoError(err)
+	defer pc.Close()
+
+	h := mlog.NewSyslogHandler(&mlog.SyslogHandlerOptions{
+		Network:        "udp",
+		Addr:           pc.LocalAddr().String(),
+		Facility:       mlog.FacilityLocal3,
+		Hostname:       "host1",
+		AppName:        "app",
+		StructuredData: true,
+	})
+	defer h.Close()
+	logger := slog.New(h).With("req", `a "quoted" ]value`).WithGroup("g")
+	logger.Warn("hello syslog", "n", 5)
+
+	buf := make([]byte, 4096)
+	tt.NoError(pc.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	n, _, err := pc.ReadFrom(buf)
+	tt.NoError(err)
+	msg := string(buf[:n])
+
+	tt.True(strings.HasPrefix(msg, "<156>1 "), msg) // local3*8 + warning
+	fields := strings.SplitN(msg, " ", 7)
+	tt.EqualValues("host1", fields[2])
+	tt.EqualValues("app", fields[3])
+	tt.EqualValues("-", fields[5])
+	tt.EqualValues(`[mlog@32473 g.n="5" req="a \"quoted\" \]value"] hello syslog`, fields[6])
+}
+
+func Test__SyslogHandler__TCP_RFC3164_OctetCounting(t *testing.T) {
+	tt := assert.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tt.NoError(err)
+	defer ln.Close()
+	received := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		for {
+			lenStr, err := rd.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(lenStr))
+			msg := make([]byte, size)
+			if _, err := io.ReadFull(rd, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	h := mlog.NewSyslogHandler(&mlog.SyslogHandlerOptions{
+		Network:  "tcp",
+		Addr:     ln.Addr().String(),
+		Format:   mlog.SyslogRFC3164,
+		Hostname: "host1",
+		AppName:  "app",
+	})
+	defer h.Close()
+	logger := slog.New(h)
+	logger.Error("first", "k", "v")
+	logger.Info("second")
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case msg := <-received:
+			tt.Contains(msg, " host1 app[")
+			tt.Contains(msg, "]: "+expected)
+			if expected == "first" {
+				tt.True(strings.HasPrefix(msg, "<11>"), msg) // user*8 + error
+				pos := strings.Index(msg, mlog.AttrsJSONprefix)
+				tt.Greater(pos, 0)
+				data := map[string]any{}
+				tt.NoError(json.Unmarshal([]byte(msg[pos+len(mlog.AttrsJSONprefix):]), &data))
+				tt.EqualValues("v", data["k"])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+}
+
+func Test__SyslogHandler__Unixgram_Reconnect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported")
+	}
+	tt := assert.New(t)
+
+	sockPath := filepath.Join(t.TempDir(), "log.sock")
+	listen := func() *net.UnixConn {
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sockPath, Net: "unixgram"})
+		tt.NoError(err)
+		return conn
+	}
+	read := func(conn *net.UnixConn) string {
+		buf := make([]byte, 4096)
+		tt.NoError(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		n, err := conn.Read(buf)
+		tt.NoError(err)
+		return string(buf[:n])
+	}
+
+	server := listen()
+	h := mlog.NewSyslogHandler(&mlog.SyslogHandlerOptions{Network: "unixgram", Addr: sockPath})
+	defer h.Close()
+	logger := slog.New(h)
+
+	logger.Info("before restart")
+	tt.Contains(read(server), "before restart")
+
+	server.Close()
+	tt.NoError(os.Remove(sockPath))
+	server = listen() // syslog daemon restarted
+	defer server.Close()
+
+	logger.Info("after restart")
+	tt.Contains(read(server), "after restart")
+}
+
+func Test__SyslogHandler__Unix_LineFraming(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not supported")
+	}
+	tt := assert.New(t)
+
+	sockPath := filepath.Join(t.TempDir(), "log.sock")
+	ln, err := net.Listen("unix", sockPath)
+	tt.NoError(err)
+	defer ln.Close()
+	received := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			received <- line
+		}
+	}()
+
+	h := mlog.NewSyslogHandler(&mlog.SyslogHandlerOptions{Network: "unix", Addr: sockPath, AppName: "app"})
+	defer h.Close()
+	logger := slog.New(h)
+	logger.Info("multi\nline")
+	logger.Info("second")
+
+	for _, expected := range []string{"multi#012line", "second"} {
+		select {
+		case msg := <-received:
+			tt.True(strings.HasPrefix(msg, "<14>1 "), msg) // no octet count
+			tt.Contains(msg, expected)
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+}
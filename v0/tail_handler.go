@@ -0,0 +1,467 @@
+package mlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TailFormat is a format of records, served by [TailHandler].
+type TailFormat int
+
+const (
+	// TailFormatJSON serves records as [TailRecord] JSON objects.
+	TailFormatJSON TailFormat = iota
+	// TailFormatHumanReadable serves records as [HumanReadableHandler] lines.
+	TailFormatHumanReadable
+)
+
+type TailHandlerOptions struct {
+	// Size is the number of recent records, kept in memory. If zero, [DefaultTailSize] is used.
+	Size int
+	// Format is the default format of records, it may be changed by the "format" query parameter.
+	Format TailFormat
+	// LineOptions configures lines of [TailFormatHumanReadable]. Level of these options is ignored.
+	LineOptions *HumanReadableHandlerOptions
+	// KeepAlive is the period of comments, sent to the idle event stream to keep the connection open.
+	// If zero, [DefaultTailKeepAlive] is used.
+	KeepAlive time.Duration
+
+	// MaxAttrDepth and MaxAttrSize limit attribute values, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to log.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelDebug].
+	Level slog.Leveler
+}
+
+// TailRecord is a record, kept by [TailHandler].
+type TailRecord struct {
+	// Seq is the sequence number of the record, it is used as the event ID of the stream.
+	Seq     uint64         `json:"seq"`
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+
+	level slog.Level
+	line  string // HumanReadableHandler line without the newline
+}
+
+// TailHandler is a [slog.Handler] that keeps recent Records in the ring buffer and serves them over HTTP
+// (see [TailHandler.ServeHTTP]): as history or as Server-Sent Events stream for live tailing in the browser.
+// It is intended to be one leg of [MultipleHandler].
+type TailHandler struct {
+	opts  TailHandlerOptions
+	state handlerState
+	inner slog.Handler // renders the line into line buffer
+	line  *lineBuffer
+	ring  *tailRing
+}
+
+// tailRing keeps records and notifies subscribers about new ones.
+type tailRing struct {
+	mu          sync.Mutex
+	records     []TailRecord
+	next        int // position of the next record in records
+	seq         uint64
+	subscribers map[chan TailRecord]struct{}
+	closed      bool
+}
+
+// NewTailHandler creates a TailHandler, using the given options.
+// If opts is nil, the default options are used.
+func NewTailHandler(opts *TailHandlerOptions) *TailHandler {
+	h := &TailHandler{
+		line: &lineBuffer{},
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelDebug
+	}
+	if h.opts.Size <= 0 {
+		h.opts.Size = DefaultTailSize
+	}
+	if h.opts.KeepAlive <= 0 {
+		h.opts.KeepAlive = DefaultTailKeepAlive
+	}
+	h.state = newHandlerState(newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize))
+	lineOpts := HumanReadableHandlerOptions{}
+	if h.opts.LineOptions != nil {
+		lineOpts = *h.opts.LineOptions
+	}
+	lineOpts.Level = slog.Level(math.MinInt)
+	h.inner = NewHumanReadableHandler(h.line, &lineOpts)
+	h.ring = &tailRing{
+		records:     make([]TailRecord, 0, h.opts.Size),
+		subscribers: map[chan TailRecord]struct{}{},
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *TailHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle stores the Record into the ring buffer and sends it to the connected streams.
+// Implements [slog.Handler] interface.
+func (h *TailHandler) Handle(ctx context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	rec := TailRecord{
+		Time:    r.Time,
+		Level:   LevelString(r.Level),
+		Message: r.Message,
+		level:   r.Level,
+	}
+	rec.Attrs, _ = h.state.tree(&r)
+
+	h.line.mu.Lock()
+	h.line.buf = h.line.buf[:0]
+	err = h.inner.Handle(ctx, r)
+	rec.line = strings.TrimSuffix(string(h.line.buf), "\n")
+	h.line.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	h.ring.add(rec)
+	return nil
+}
+
+func (t *tailRing) add(rec TailRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	rec.Seq = t.seq
+	if len(t.records) < cap(t.records) {
+		t.records = append(t.records, rec)
+	} else {
+		t.records[t.next] = rec
+		t.next = (t.next + 1) % len(t.records)
+	}
+	for ch := range t.subscribers {
+		select {
+		case ch <- rec:
+		default: // the slow client misses records rather than blocks logging
+		}
+	}
+}
+
+// history returns kept records with sequence numbers above since, the oldest first.
+func (t *tailRing) history(since uint64) []TailRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.after(since)
+}
+
+// after returns kept records with sequence numbers above since. Lock must be held.
+func (t *tailRing) after(since uint64) []TailRecord {
+	rv := make([]TailRecord, 0, len(t.records))
+	for i := range t.records {
+		if rec := t.records[(t.next+i)%len(t.records)]; rec.Seq > since {
+			rv = append(rv, rec)
+		}
+	}
+	return rv
+}
+
+// subscribe returns records with sequence numbers above since and the channel of new records.
+// The channel is nil if the handler is closed.
+func (t *tailRing) subscribe(since uint64) ([]TailRecord, chan TailRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	history := t.after(since)
+	if t.closed {
+		return history, nil
+	}
+	ch := make(chan TailRecord, tailSubscriberBuffer)
+	t.subscribers[ch] = struct{}{}
+	return history, ch
+}
+
+func (t *tailRing) unsubscribe(ch chan TailRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.subscribers[ch]; ok {
+		delete(t.subscribers, ch)
+		close(ch)
+	}
+}
+
+// WithAttrs returns a new TailHandler whose attributes consists of h's attributes followed by attrs.
+// Implements [slog.Handler] interface.
+func (h *TailHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	hh := *h
+	hh.state = h.state.withAttrs(aa)
+	hh.inner = h.inner.WithAttrs(aa)
+	return &hh
+}
+
+// WithGroup returns a new TailHandler with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *TailHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	hh := *h
+	hh.state = h.state.withGroup(name)
+	hh.inner = h.inner.WithGroup(name)
+	return &hh
+}
+
+// Close ends all event streams. Records are still kept and served as history.
+// Later calls do nothing, so handlers, derived by WithAttrs and WithGroup, may be closed one by one.
+func (h *TailHandler) Close() error {
+	t := h.ring
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	for ch := range t.subscribers {
+		delete(t.subscribers, ch)
+		close(ch)
+	}
+	return nil
+}
+
+// ServeHTTP returns kept records, the oldest first, as JSON array or text lines. If the request accepts
+// "text/event-stream" or has "follow" query parameter, it streams records as Server-Sent Events instead:
+// the kept records after "since" parameter or Last-Event-ID header, or last "limit" ones, and then new ones;
+// without these parameters only new records are streamed.
+// Query parameters:
+//   - "format" is "json" or "human";
+//   - "level" is the minimum level, like "warn";
+//   - "message" is a regular expression, the message must match;
+//   - "attr" is "key=value", the attribute must have the value; keys of nested groups are joined by dot;
+//     the parameter may be repeated;
+//   - "since" is the sequence number, only later records are returned;
+//   - "limit" is the maximum number of last records to return.
+//
+// Implements [http.Handler] interface.
+func (h *TailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := h.parseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Has("follow") || strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		h.stream(w, r, q)
+		return
+	}
+
+	records := q.filter(h.ring.history(q.since))
+	if q.format == TailFormatHumanReadable {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for i := range records {
+			fmt.Fprintln(w, records[i].line)
+		}
+		return
+	}
+	writeJSON(w, records)
+}
+
+func (h *TailHandler) stream(w http.ResponseWriter, r *http.Request, q *tailQuery) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	if !q.sinceSet && q.limit < 0 {
+		q.limit = 0 // only new records by default
+	}
+	history, ch := h.ring.subscribe(q.since)
+	if ch != nil {
+		defer h.ring.unsubscribe(ch)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, rec := range q.filter(history) {
+		q.writeEvent(w, &rec)
+	}
+	flusher.Flush()
+	if ch == nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(h.opts.KeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case rec, ok := <-ch:
+			if !ok {
+				return
+			}
+			if !q.match(&rec) {
+				continue
+			}
+			q.writeEvent(w, &rec)
+		}
+		flusher.Flush()
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// tailQuery is the parsed request of [TailHandler.ServeHTTP].
+type tailQuery struct {
+	format   TailFormat
+	level    slog.Level
+	message  *regexp.Regexp
+	attrs    map[string]string
+	since    uint64
+	sinceSet bool
+	limit    int // negative if not set
+}
+
+func (h *TailHandler) parseQuery(r *http.Request) (*tailQuery, error) {
+	values := r.URL.Query()
+	q := &tailQuery{
+		format: h.opts.Format,
+		level:  slog.Level(math.MinInt),
+		limit:  -1,
+	}
+	switch values.Get("format") {
+	case "":
+	case "json":
+		q.format = TailFormatJSON
+	case "human":
+		q.format = TailFormatHumanReadable
+	default:
+		return nil, errors.New("invalid format") //nolint:goerr113
+	}
+	if s := values.Get("level"); s != "" {
+		var err error
+		if q.level, err = ParseLevel(s); err != nil {
+			return nil, errors.New("invalid level") //nolint:goerr113
+		}
+	}
+	if s := values.Get("message"); s != "" {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message expression: %w", err)
+		}
+		q.message = re
+	}
+	for _, s := range values["attr"] {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, errors.New("invalid attr, key=value is expected") //nolint:goerr113
+		}
+		if q.attrs == nil {
+			q.attrs = map[string]string{}
+		}
+		q.attrs[k] = v
+	}
+	since := values.Get("since")
+	if since == "" {
+		since = r.Header.Get("Last-Event-ID")
+	}
+	if since != "" {
+		n, err := strconv.ParseUint(since, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid since") //nolint:goerr113
+		}
+		q.since, q.sinceSet = n, true
+	}
+	if s := values.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, errors.New("invalid limit") //nolint:goerr113
+		}
+		q.limit = n
+	}
+	return q, nil
+}
+
+// filter returns matching records, limited by the query limit.
+func (q *tailQuery) filter(records []TailRecord) []TailRecord {
+	rv := records[:0:0]
+	for i := range records {
+		if q.match(&records[i]) {
+			rv = append(rv, records[i])
+		}
+	}
+	if q.limit >= 0 && len(rv) > q.limit {
+		rv = rv[len(rv)-q.limit:]
+	}
+	return rv
+}
+
+func (q *tailQuery) match(rec *TailRecord) bool {
+	if rec.level < q.level {
+		return false
+	}
+	if q.message != nil && !q.message.MatchString(rec.Message) {
+		return false
+	}
+	for k, v := range q.attrs {
+		value, ok := tailAttr(rec.Attrs, k)
+		if !ok || formatScalar(value) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *tailQuery) writeEvent(w http.ResponseWriter, rec *TailRecord) {
+	data := rec.line
+	if q.format == TailFormatJSON {
+		b, _ := json.Marshal(rec) //nolint:errchkjson
+		data = string(b)
+	}
+	fmt.Fprintf(w, "id: %d\n", rec.Seq)
+	for _, line := range strings.Split(data, "\n") { // stack traces may be multiline
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// tailAttr returns the value of the attribute by the key, keys of nested groups are joined by dot.
+func tailAttr(tree jsonTree, key string) (any, bool) {
+	if v, ok := tree[key]; ok {
+		return v, true
+	}
+	for k, v := range tree {
+		if sub, ok := v.(jsonTree); ok && strings.HasPrefix(key, k+".") {
+			if rv, ok := tailAttr(sub, key[len(k)+1:]); ok {
+				return rv, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,106 @@
+package mlog_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__TailHandler__History(t *testing.T) {
+	tt := assert.New(t)
+
+	h := mlog.NewTailHandler(&mlog.TailHandlerOptions{Size: 3})
+	server := httptest.NewServer(h)
+	defer server.Close()
+	logger := slog.New(h)
+
+	logger.Debug("dropped from the ring")
+	logger.Info("first", "user", "bob")
+	logger.With(slog.Group("http", "status", 500)).Error("second", "user", "alice")
+	logger.Warn("third")
+
+	get := func(query string) string {
+		resp, err := http.Get(server.URL + "/?" + query)
+		tt.NoError(err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		tt.NoError(err)
+		tt.EqualValues(http.StatusOK, resp.StatusCode, string(body))
+		return string(body)
+	}
+	var records any
+	tt.NoError(json.Unmarshal([]byte(get("")), &records))
+	tt.True(Jq(t, records, `map(.message) == ["first", "second", "third"]`))
+	tt.True(Jq(t, records, `map(.seq) == [2, 3, 4]`))
+
+	tt.NoError(json.Unmarshal([]byte(get("level=warn&limit=1")), &records))
+	tt.True(Jq(t, records, `map(.message) == ["third"]`))
+	tt.NoError(json.Unmarshal([]byte(get("attr=http.status=500&attr=user=alice")), &records))
+	tt.True(Jq(t, records, `map(.message) == ["second"]`))
+	tt.NoError(json.Unmarshal([]byte(get("message=^f&since=1")), &records))
+	tt.True(Jq(t, records, `map(.message) == ["first"]`))
+
+	lines := get("format=human&since=2")
+	tt.EqualValues(2, strings.Count(lines, "\n"))
+	tt.Contains(lines, `E --  second  ATTRS={"http":{"status":500},"user":"alice"}`)
+
+	resp, err := http.Get(server.URL + "/?level=loud")
+	tt.NoError(err)
+	resp.Body.Close()
+	tt.EqualValues(http.StatusBadRequest, resp.StatusCode)
+}
+
+func Test__TailHandler__Stream(t *testing.T) {
+	tt := assert.New(t)
+
+	h := mlog.NewTailHandler(&mlog.TailHandlerOptions{Format: mlog.TailFormatHumanReadable})
+	server := httptest.NewServer(h)
+	defer server.Close()
+	logger := slog.New(h)
+	logger.Info("old")
+	logger.Info("missed")
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/?level=info", nil)
+	tt.NoError(err)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	tt.NoError(err)
+	defer resp.Body.Close()
+	tt.EqualValues("text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		var event strings.Builder
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if scanner.Text() == "" {
+				events <- event.String()
+				event.Reset()
+				continue
+			}
+			event.WriteString(scanner.Text() + "\n")
+		}
+	}()
+
+	tt.Contains(<-events, "id: 2\ndata: ")
+	logger.Debug("filtered")
+	logger.Info("new", "n", 1)
+	event := <-events
+	tt.Contains(event, "id: 4\n")
+	tt.Contains(event, `new  ATTRS={"n":1}`)
+
+	tt.NoError(h.Close())
+	_, ok := <-events
+	tt.False(ok)
+	tt.NoError(h.Close()) // closing again is no-op
+}
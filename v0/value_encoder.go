@@ -0,0 +1,228 @@
+package mlog
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"time"
+	"unicode/utf8"
+)
+
+// valueEncoder converts [slog.Value] into a tree which can always be serialized by [json.Marshal].
+// Values which can not be marshaled as is are replaced by their string representation,
+// so one bad attribute never drops the whole ATTRS block.
+type valueEncoder struct {
+	maxDepth     int
+	maxSize      int
+	errorDetails bool // render errors as [ErrorInfo] instead of Error() text
+}
+
+func newValueEncoder(maxDepth, maxSize int) valueEncoder {
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxAttrDepth
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxAttrSize
+	}
+	return valueEncoder{
+		maxDepth: maxDepth,
+		maxSize:  maxSize,
+	}
+}
+
+// addAttr stores JSON-safe representation of the attribute into dst.
+// Attributes with empty key and group value are inlined, empty groups are ignored, like [slog.JSONHandler] does.
+func (e valueEncoder) addAttr(dst jsonTree, a slog.Attr, depth int) {
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	v := e.resolve(a.Value)
+	if v.Kind() == slog.KindGroup {
+		aa := v.Group()
+		if len(aa) == 0 {
+			return
+		}
+		if a.Key == "" {
+			for i := range aa {
+				e.addAttr(dst, aa[i], depth)
+			}
+			return
+		}
+	}
+	dst[a.Key] = e.value(v, depth)
+}
+
+// resolve calls LogValue() of the [slog.LogValuer] values, recovering from panic.
+// Unlike [slog.Value.Resolve] the panic message is kept in the result.
+func (e valueEncoder) resolve(v slog.Value) (rv slog.Value) {
+	defer func() {
+		if p := recover(); p != nil {
+			rv = slog.StringValue(fmt.Sprintf(panicValueFormat, p))
+		}
+	}()
+	for i := 0; v.Kind() == slog.KindLogValuer; i++ {
+		if i >= maxLogValuerCalls {
+			return slog.StringValue(maxDepthValue)
+		}
+		v = v.LogValuer().LogValue()
+	}
+	return v
+}
+
+// value returns JSON-safe representation of the resolved value.
+func (e valueEncoder) value(v slog.Value, depth int) (rv any) {
+	defer func() {
+		if p := recover(); p != nil {
+			rv = fmt.Sprintf(panicValueFormat, p)
+		}
+	}()
+	switch v.Kind() {
+	case slog.KindString:
+		return e.truncate(v.String())
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindFloat64:
+		return floatValue(v.Float64())
+	case slog.KindDuration:
+		return int64(v.Duration())
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindGroup:
+		if depth >= e.maxDepth {
+			return maxDepthValue
+		}
+		tree := jsonTree{}
+		aa := v.Group()
+		for i := range aa {
+			e.addAttr(tree, aa[i], depth+1)
+		}
+		return tree
+	case slog.KindLogValuer:
+		return e.value(e.resolve(v), depth)
+	case slog.KindAny:
+	}
+	return e.anyValue(v.Any(), depth)
+}
+
+// anyValue returns JSON-safe representation of the arbitrary Go value.
+func (e valueEncoder) anyValue(x any, depth int) (rv any) {
+	defer func() {
+		if p := recover(); p != nil {
+			rv = fmt.Sprintf(panicValueFormat, p)
+		}
+	}()
+	if x == nil || isNilPointer(x) {
+		return nil
+	}
+	switch xx := x.(type) {
+	case error:
+		if e.errorDetails {
+			rv := newErrorInfo(xx, depth, e.maxDepth)
+			e.truncateErrorInfo(rv)
+			return rv
+		}
+		return e.truncate(xx.Error())
+	case json.Marshaler:
+		return e.marshal(xx, depth)
+	case encoding.TextMarshaler:
+		b, err := xx.MarshalText()
+		if err != nil {
+			return fmt.Sprintf(errorValueFormat, err)
+		}
+		return e.truncate(string(b))
+	case fmt.Stringer:
+		return e.truncate(xx.String())
+	case float64:
+		return floatValue(xx)
+	case float32:
+		return floatValue(float64(xx))
+	case slog.Value:
+		return e.value(xx, depth)
+	case []slog.Attr:
+		return e.value(slog.GroupValue(xx...), depth)
+	}
+	return e.marshal(x, depth)
+}
+
+// marshal tries to marshal value by [json.Marshal]. If fails, values of containers
+// are processed one by one, and other values are rendered as %+v string.
+func (e valueEncoder) marshal(x any, depth int) any {
+	b, err := json.Marshal(x)
+	if err == nil {
+		if len(b) > e.maxSize {
+			return e.truncate(string(b))
+		}
+		return json.RawMessage(b)
+	}
+	if depth >= e.maxDepth {
+		return maxDepthValue
+	}
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() { //nolint:exhaustive
+	case reflect.Map:
+		tree := jsonTree{}
+		iter := rv.MapRange()
+		for iter.Next() {
+			tree[fmt.Sprint(iter.Key().Interface())] = e.anyValue(iter.Value().Interface(), depth+1)
+		}
+		return tree
+	case reflect.Slice, reflect.Array:
+		list := make([]any, rv.Len())
+		for i := range list {
+			list[i] = e.anyValue(rv.Index(i).Interface(), depth+1)
+		}
+		return list
+	case reflect.Pointer:
+		return e.anyValue(rv.Elem().Interface(), depth+1)
+	}
+	return e.truncate(fmt.Sprintf("%+v", x))
+}
+
+// truncateErrorInfo truncates messages of the error and all errors in its chain.
+func (e valueEncoder) truncateErrorInfo(info *ErrorInfo) {
+	info.Msg = e.truncate(info.Msg)
+	for _, c := range info.Chain {
+		e.truncateErrorInfo(c)
+	}
+}
+
+// truncate cuts s to maxSize bytes at the rune boundary, so the result is valid UTF-8 if s is.
+func (e valueEncoder) truncate(s string) string {
+	if len(s) <= e.maxSize {
+		return s
+	}
+	n := e.maxSize
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + truncatedValueSuffix
+}
+
+// floatValue returns NaN and Inf as a strings, because JSON has no representation for them.
+func floatValue(f float64) any {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return f
+}
+
+func isNilPointer(x any) bool {
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() { //nolint:exhaustive
+	case reflect.Pointer, reflect.Interface, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+//nolint:goconst
+package mlog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+type cyclicStruct struct {
+	Name string
+	Next *cyclicStruct
+}
+
+type panicMarshaler struct{}
+
+func (panicMarshaler) MarshalJSON() ([]byte, error) {
+	panic("MarshalJSON is broken")
+}
+
+type panicValuer struct{}
+
+func (panicValuer) LogValue() slog.Value {
+	panic("LogValue is broken")
+}
+
+type stringerValue struct{ v int }
+
+func (s stringerValue) String() string {
+	return "stringer-" + string(rune('0'+s.v))
+}
+
+func hrAttrs(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	pos := bytes.Index(buf.Bytes(), []byte(mlog.AttrsJSONprefix))
+	if pos < 0 {
+		t.Fatalf("no ATTRS block in: %s", buf.String())
+	}
+	jsonBuf := buf.Bytes()[pos+len(mlog.AttrsJSONprefix):]
+	rv := map[string]any{}
+	if err := json.Unmarshal(jsonBuf, &rv); err != nil {
+		t.Fatalf("unable to unmarshal ATTRS block: %s: %s", err, buf.String())
+	}
+	return rv
+}
+
+func Test__ValueEncoder__UnmarshalableValues(t *testing.T) {
+	tt := assert.New(t)
+
+	cyclic := &cyclicStruct{Name: "loop"}
+	cyclic.Next = cyclic
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, nil))
+	logger.Info("bad values",
+		"chan", make(chan int),
+		"func", func() {},
+		"cyclic", cyclic,
+		"nan", math.NaN(),
+		"inf", math.Inf(-1),
+		"anyNaN", any(float32(math.NaN())),
+		"map", map[string]any{"ok": 1, "bad": make(chan int)},
+		"good", 42,
+	)
+
+	tt.NotContains(svWriter.String(), "slogERR")
+	svData := hrAttrs(t, svWriter)
+	tt.EqualValues(42, svData["good"])
+	tt.EqualValues("NaN", svData["nan"])
+	tt.EqualValues("-Inf", svData["inf"])
+	tt.EqualValues("NaN", svData["anyNaN"])
+	tt.NotZero(JqGetString(svData, ".chan"))
+	tt.NotZero(JqGetString(svData, ".func"))
+	tt.Contains(JqGetString(svData, ".cyclic"), "loop")
+	tt.EqualValues(1, svData["map"].(map[string]any)["ok"])
+	tt.NotZero(JqGetString(svData, ".map.bad"))
+}
+
+func Test__ValueEncoder__Interfaces(t *testing.T) {
+	tt := assert.New(t)
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, nil))
+	logger.Info("interfaces",
+		"err", errors.New("something wrong"),
+		"stringer", stringerValue{v: 7},
+		"text", net.ParseIP("10.0.0.1"),
+		"nilErr", error(nil),
+	)
+
+	svData := hrAttrs(t, svWriter)
+	tt.EqualValues("something wrong", svData["err"])
+	tt.EqualValues("stringer-7", svData["stringer"])
+	tt.EqualValues("10.0.0.1", svData["text"])
+	tt.Contains(svData, "nilErr")
+	tt.Nil(svData["nilErr"])
+}
+
+func Test__ValueEncoder__PanicRecovery(t *testing.T) {
+	tt := assert.New(t)
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, nil))
+	tt.NotPanics(func() {
+		logger.Info("panics", "marshaler", panicMarshaler{}, "valuer", panicValuer{}, "good", "yes")
+	})
+
+	svData := hrAttrs(t, svWriter)
+	tt.EqualValues("yes", svData["good"])
+	tt.Contains(JqGetString(svData, ".marshaler"), "MarshalJSON is broken")
+	tt.Contains(JqGetString(svData, ".valuer"), "LogValue is broken")
+}
+
+func Test__ValueEncoder__Limits(t *testing.T) {
+	tt := assert.New(t)
+
+	svWriter := &bytes.Buffer{}
+	logger := slog.New(mlog.NewHumanReadableHandler(svWriter, &mlog.HumanReadableHandlerOptions{
+		MaxAttrDepth: 2,
+		MaxAttrSize:  16,
+	}))
+	logger.Info("limits",
+		"long", strings.Repeat("x", 100),
+		"deep", slog.GroupValue(slog.Group("a", slog.Group("b", slog.Int("c", 1)))),
+		"short", "abc",
+		"runes", "x"+strings.Repeat("ж", 20),
+	)
+
+	svData := hrAttrs(t, svWriter)
+	tt.EqualValues("abc", svData["short"])
+	tt.True(strings.HasPrefix(JqGetString(svData, ".long"), strings.Repeat("x", 16)))
+	tt.Less(len(JqGetString(svData, ".long")), 100)
+	tt.EqualValues("x"+strings.Repeat("ж", 7)+"...!TRUNCATED", JqGetString(svData, ".runes"))
+	tt.True(utf8.ValidString(svWriter.String()))
+	tt.True(Jq(t, svData, `.deep.a | type == "object"`))
+	tt.True(Jq(t, svData, `.deep.a.b | type == "string"`))
+}
+
+func Test__ValueEncoder__GroupAttrs(t *testing.T) {
+	tt := assert.New(t)
+
+	nativeWriter := &bytes.Buffer{}
+	svWriter := &bytes.Buffer{}
+	nativeHandler := slog.NewJSONHandler(nativeWriter, nil)
+	svHandler := mlog.NewHumanReadableHandler(svWriter, nil)
+	logger := slog.New(mlog.NewMultipleHandler(nil, nativeHandler, svHandler)).With(slog.Group("req", "id", 5))
+
+	logger.Info("groups", slog.Group("resp", "code", 200), slog.Group("", "inlined", true), slog.Group("empty"))
+
+	nativeData := map[string]any{}
+	tt.NoError(json.Unmarshal(nativeWriter.Bytes(), &nativeData))
+	svData := hrAttrs(t, svWriter)
+
+	for _, q := range []string{".req.id", ".resp.code", ".inlined", "has(\"empty\")"} {
+		nativeVal, err := JqGet(nativeData, q)
+		tt.NoError(err)
+		svVal, err := JqGet(svData, q)
+		tt.NoError(err)
+		tt.EqualValues(nativeVal, svVal, q)
+	}
+}
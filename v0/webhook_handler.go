@@ -0,0 +1,431 @@
+package mlog
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// WebhookFormat is a payload format of the notification.
+type WebhookFormat int
+
+const (
+	// WebhookFormatSlack sends {"text": "..."} payload, accepted by Slack, Mattermost and Rocket.Chat incoming webhooks.
+	WebhookFormatSlack WebhookFormat = iota
+	// WebhookFormatGeneric sends [WebhookNotification] as JSON.
+	WebhookFormatGeneric
+)
+
+type WebhookHandlerOptions struct {
+	// URL is the webhook URL.
+	URL string
+	// Format is the payload format, Slack by default.
+	Format WebhookFormat
+	// Template renders the payload, if set, instead of Format. It is executed with [WebhookNotification],
+	// see [ParseWebhookTemplate].
+	Template *template.Template
+	// Headers are added to each request.
+	Headers map[string]string
+	// Client is used to send requests. If nil, the client with [DefaultHTTPTimeout] is used.
+	Client *http.Client
+
+	// ThrottleInterval limits notifications about the same message (the same level and message text):
+	// repeated records within the interval are not sent, but counted in the next notification,
+	// which is sent at the end of the interval, with attributes of the last repeated record.
+	// If zero, [DefaultWebhookThrottleInterval] is used, negative value disables throttling.
+	ThrottleInterval time.Duration
+
+	// BreakerThreshold is the number of consecutive failed requests, which opens the circuit breaker:
+	// notifications are dropped without requests during BreakerCooldown.
+	// If zero, [DefaultBreakerThreshold] and [DefaultBreakerCooldown] are used.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Batch configures grouping of bursts and retries: records, which come within Batch.FlushInterval,
+	// are sent as one notification. If Batch.FlushInterval is zero, [DefaultWebhookGroupInterval] is used.
+	Batch BatchOptions
+
+	// MaxAttrDepth and MaxAttrSize limit attribute values, see [HumanReadableHandlerOptions].
+	MaxAttrDepth int
+	MaxAttrSize  int
+
+	// Level reports the minimum level to log.
+	// Levels with lower levels are discarded.
+	// If nil, the Handler uses [slog.LevelError].
+	Level slog.Leveler
+}
+
+// WebhookRecord is a record in the [WebhookNotification].
+type WebhookRecord struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	// Count is the number of the same records, including throttled ones.
+	Count int `json:"count"`
+}
+
+// WebhookNotification is the data of one notification, it is sent as is with [WebhookFormatGeneric]
+// and passed to [WebhookHandlerOptions.Template].
+type WebhookNotification struct {
+	// Text is the human readable summary of records.
+	Text    string          `json:"text"`
+	Records []WebhookRecord `json:"records"`
+}
+
+// WebhookHandler is a [slog.Handler] that sends notifications about Records (errors, by default) to the chat
+// or alerting webhook. Records are throttled, grouped and sent by the background goroutine with retries,
+// the circuit breaker stops requests to the failing webhook for a while.
+// Handle never blocks, so the handler is safe to use as one leg of [MultipleHandler].
+type WebhookHandler struct {
+	opts     WebhookHandlerOptions
+	state    handlerState
+	throttle *throttle
+	batcher  *batcher[WebhookRecord]
+}
+
+// throttle counts records with the same fingerprint, sent within the interval. Counts of suppressed
+// records are reported at the end of the interval, unless the next record of the fingerprint is sent before.
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	report   func(rec *WebhookRecord) // sends the suppressed records
+	seen     map[string]*list.Element // of *throttleEntry
+	order    list.List                // of *throttleEntry, the least recently sent first
+	closed   bool
+}
+
+type throttleEntry struct {
+	key        string
+	sent       time.Time
+	suppressed int
+	last       WebhookRecord // the last suppressed record
+	timer      *time.Timer   // reports suppressed records at the end of the interval
+}
+
+// allow reports whether the record should be sent and returns the number of records, suppressed before it.
+func (t *throttle) allow(key string, rec *WebhookRecord) (bool, int) {
+	if t.interval <= 0 {
+		return true, 0
+	}
+	t.mu.Lock()
+	if el, ok := t.seen[key]; ok {
+		e := el.Value.(*throttleEntry) //nolint:forcetypeassert
+		if elapsed := rec.Time.Sub(e.sent); elapsed < t.interval {
+			e.suppressed++
+			e.last = *rec
+			if e.timer == nil && !t.closed {
+				e.timer = time.AfterFunc(t.interval-elapsed, func() { t.expire(el) })
+			}
+			t.mu.Unlock()
+			return false, 0
+		}
+		suppressed := e.suppressed
+		e.stop()
+		e.sent, e.suppressed = rec.Time, 0
+		t.order.MoveToBack(el)
+		t.mu.Unlock()
+		return true, suppressed
+	}
+	var evicted *WebhookRecord
+	if t.order.Len() >= maxThrottleEntries {
+		evicted = t.remove(t.order.Front())
+	}
+	t.seen[key] = t.order.PushBack(&throttleEntry{key: key, sent: rec.Time})
+	t.mu.Unlock()
+	if evicted != nil {
+		t.report(evicted)
+	}
+	return true, 0
+}
+
+// expire reports records of the entry, suppressed during the interval.
+func (t *throttle) expire(el *list.Element) {
+	t.mu.Lock()
+	e := el.Value.(*throttleEntry)                         //nolint:forcetypeassert
+	if t.seen[e.key] != el || e.timer == nil || t.closed { // evicted, sent or closed meanwhile
+		t.mu.Unlock()
+		return
+	}
+	e.timer = nil
+	rec := e.last
+	rec.Count = e.suppressed
+	e.sent, e.suppressed = time.Now(), 0
+	t.order.MoveToBack(el)
+	t.mu.Unlock()
+	t.report(&rec)
+}
+
+// remove removes the entry and returns its suppressed records, if any. Lock must be held.
+func (t *throttle) remove(el *list.Element) *WebhookRecord {
+	e := t.order.Remove(el).(*throttleEntry) //nolint:forcetypeassert
+	delete(t.seen, e.key)
+	e.stop()
+	if e.suppressed == 0 {
+		return nil
+	}
+	rec := e.last
+	rec.Count = e.suppressed
+	return &rec
+}
+
+// close stops timers and reports all suppressed records.
+func (t *throttle) close() {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.closed = true
+	var pending []*WebhookRecord
+	for t.order.Len() != 0 {
+		if rec := t.remove(t.order.Front()); rec != nil {
+			pending = append(pending, rec)
+		}
+	}
+	t.mu.Unlock()
+	for _, rec := range pending {
+		t.report(rec)
+	}
+}
+
+func (e *throttleEntry) stop() {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+}
+
+// circuitBreaker rejects requests during cooldown after threshold consecutive failures.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+func (b *circuitBreaker) call(fn func() error) error {
+	b.mu.Lock()
+	if time.Now().Before(b.openUntil) {
+		b.mu.Unlock()
+		return permanentError{ErrCircuitOpen}
+	}
+	b.mu.Unlock()
+
+	err := fn()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return nil
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.failures = 0
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+	return err
+}
+
+// NewWebhookHandler creates a WebhookHandler, using the given options, and starts the background sending goroutine.
+func NewWebhookHandler(opts *WebhookHandlerOptions) *WebhookHandler {
+	h := &WebhookHandler{}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelError
+	}
+	if h.opts.Client == nil {
+		h.opts.Client = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+	if h.opts.ThrottleInterval == 0 {
+		h.opts.ThrottleInterval = DefaultWebhookThrottleInterval
+	}
+	if h.opts.BreakerThreshold <= 0 {
+		h.opts.BreakerThreshold = DefaultBreakerThreshold
+	}
+	if h.opts.BreakerCooldown <= 0 {
+		h.opts.BreakerCooldown = DefaultBreakerCooldown
+	}
+	if h.opts.Batch.FlushInterval <= 0 {
+		h.opts.Batch.FlushInterval = DefaultWebhookGroupInterval
+	}
+	h.state = newHandlerState(newValueEncoder(h.opts.MaxAttrDepth, h.opts.MaxAttrSize))
+	h.throttle = &throttle{
+		interval: h.opts.ThrottleInterval,
+		seen:     map[string]*list.Element{},
+		report: func(rec *WebhookRecord) {
+			h.batcher.add(*rec) //nolint:errcheck,gosec // counted as dropped
+		},
+	}
+
+	sender := httpSender{client: h.opts.Client, url: h.opts.URL, headers: h.opts.Headers}
+	breaker := &circuitBreaker{threshold: h.opts.BreakerThreshold, cooldown: h.opts.BreakerCooldown}
+	h.batcher = newBatcher(h.opts.Batch, func(ctx context.Context, batch []WebhookRecord) error {
+		body, err := h.payload(newWebhookNotification(batch))
+		if err != nil {
+			return permanentError{err}
+		}
+		return breaker.call(func() error {
+			_, err := sender.post(ctx, "application/json", body)
+			return err
+		})
+	})
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level. The handler ignores records whose level is lower.
+// Implements [slog.Handler] interface.
+func (h *WebhookHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle queues the Record for notification, unless it is throttled.
+// [ErrBufferFull] is returned if the queue is full.
+// Implements [slog.Handler] interface.
+func (h *WebhookHandler) Handle(_ context.Context, r slog.Record) (err error) { //nolint:gocritic
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(Error, fmt.Errorf(panicValueFormat, p)) //nolint:goerr113
+		}
+	}()
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	attrs, _ := h.state.tree(&r)
+	rec := WebhookRecord{
+		Time:    t,
+		Level:   LevelString(r.Level),
+		Message: r.Message,
+		Attrs:   attrs,
+	}
+	ok, suppressed := h.throttle.allow(rec.Level+" "+rec.Message, &rec)
+	if !ok {
+		return nil
+	}
+	rec.Count = suppressed + 1
+	if err := h.batcher.add(rec); err != nil {
+		return errors.Join(Error, err)
+	}
+	return nil
+}
+
+func (h *WebhookHandler) payload(n *WebhookNotification) ([]byte, error) {
+	if h.opts.Template != nil {
+		var buf bytes.Buffer
+		if err := h.opts.Template.Execute(&buf, n); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		return buf.Bytes(), nil
+	}
+	if h.opts.Format == WebhookFormatGeneric {
+		return json.Marshal(n) //nolint:wrapcheck
+	}
+	return json.Marshal(map[string]string{"text": n.Text}) //nolint:wrapcheck
+}
+
+// WithAttrs returns a new WebhookHandler whose attributes consists of h's attributes followed by attrs.
+// Implements [slog.Handler] interface.
+func (h *WebhookHandler) WithAttrs(aa []slog.Attr) slog.Handler {
+	return &WebhookHandler{
+		opts:     h.opts,
+		state:    h.state.withAttrs(aa),
+		throttle: h.throttle,
+		batcher:  h.batcher,
+	}
+}
+
+// WithGroup returns a new WebhookHandler with the given group appended to the receiver's existing groups.
+// Implements [slog.Handler] interface.
+func (h *WebhookHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &WebhookHandler{
+		opts:     h.opts,
+		state:    h.state.withGroup(name),
+		throttle: h.throttle,
+		batcher:  h.batcher,
+	}
+}
+
+// Flush sends all queued records and returns the error of the last failed request.
+func (h *WebhookHandler) Flush(ctx context.Context) error {
+	return h.batcher.flush(ctx)
+}
+
+// Close sends queued records, including counts of throttled ones, during [BatchOptions.CloseTimeout]
+// and stops the background goroutine. The queue is shared by all handlers, derived by WithAttrs and WithGroup.
+func (h *WebhookHandler) Close() error {
+	h.throttle.close()
+	return h.batcher.close()
+}
+
+// Dropped returns the number of records, which were dropped because of queue overflow,
+// failed requests or the open circuit breaker.
+func (h *WebhookHandler) Dropped() uint64 {
+	return h.batcher.dropped.Load()
+}
+
+// -----------------------------------------------------------------------------
+
+// newWebhookNotification merges the same records of the burst and builds the summary text.
+func newWebhookNotification(batch []WebhookRecord) *WebhookNotification {
+	n := &WebhookNotification{}
+	index := map[string]int{}
+	for _, rec := range batch {
+		key := rec.Level + " " + rec.Message
+		if i, ok := index[key]; ok {
+			n.Records[i].Count += rec.Count
+			continue
+		}
+		index[key] = len(n.Records)
+		n.Records = append(n.Records, rec)
+	}
+
+	var text strings.Builder
+	for i, rec := range n.Records {
+		if i == maxWebhookLines {
+			fmt.Fprintf(&text, "...and %d more\n", len(n.Records)-i)
+			break
+		}
+		fmt.Fprintf(&text, "[%s] %s", rec.Level, rec.Message)
+		if rec.Count > 1 {
+			fmt.Fprintf(&text, " (x%d)", rec.Count)
+		}
+		if len(rec.Attrs) != 0 {
+			if b, err := json.Marshal(rec.Attrs); err == nil {
+				text.WriteString("  " + AttrsJSONprefix)
+				text.Write(b)
+			}
+		}
+		text.WriteByte('\n')
+	}
+	n.Text = strings.TrimSuffix(text.String(), "\n")
+	return n
+}
+
+// ParseWebhookTemplate parses the payload template for [WebhookHandlerOptions.Template].
+// "json" function is available in the template to encode values, like {"msg": {{ json .Text }}}.
+func ParseWebhookTemplate(text string) (*template.Template, error) {
+	return template.New("webhook").Funcs(template.FuncMap{"json": webhookJSON}).Parse(text) //nolint:wrapcheck
+}
+
+// webhookJSON is the "json" function of the payload template.
+func webhookJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err //nolint:wrapcheck
+}
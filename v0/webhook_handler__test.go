@@ -0,0 +1,105 @@
+package mlog_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	mlog "github.com/xenolog/mlog/v0"
+)
+
+func Test__WebhookHandler__Slack_Throttle(t *testing.T) {
+	tt := assert.New(t)
+
+	c := newCollector(t)
+	h := mlog.NewWebhookHandler(&mlog.WebhookHandlerOptions{
+		URL:              c.server.URL,
+		ThrottleInterval: 100 * time.Millisecond,
+	})
+	defer h.Close()
+	logger := slog.New(h)
+
+	logger.Info("ignored")
+	for i := 0; i < 3; i++ {
+		logger.Error("db down", "try", i)
+	}
+	logger.Error("other")
+	tt.NoError(h.Flush(flushCtx(t)))
+	_, bodies := c.received()
+	tt.Len(bodies, 1) // the burst is grouped into one notification
+
+	tt.Eventually(func() bool { // throttled records are reported at the end of the interval
+		tt.NoError(h.Flush(flushCtx(t)))
+		_, bodies = c.received()
+		return len(bodies) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	time.Sleep(150 * time.Millisecond)
+	logger.Error("db down", "try", 3)
+	logger.Error("db down", "try", 4)
+	tt.NoError(h.Close()) // reports throttled records too
+
+	_, bodies = c.received()
+	tt.Len(bodies, 3)
+	texts := []string{}
+	for _, body := range bodies {
+		data := map[string]string{}
+		tt.NoError(json.Unmarshal(body, &data))
+		texts = append(texts, data["text"])
+	}
+	tt.EqualValues("[ERROR] db down  ATTRS={\"try\":0}\n[ERROR] other", texts[0])
+	tt.EqualValues("[ERROR] db down (x2)  ATTRS={\"try\":2}", texts[1]) // 2 throttled records are counted
+	tt.EqualValues("[ERROR] db down (x2)  ATTRS={\"try\":3}", texts[2])
+}
+
+func Test__WebhookHandler__Template(t *testing.T) {
+	tt := assert.New(t)
+
+	tmpl, err := mlog.ParseWebhookTemplate(`{"alert": {{ json (index .Records 0).Message }}, "count": {{ len .Records }}}`)
+	tt.NoError(err)
+	c := newCollector(t)
+	h := mlog.NewWebhookHandler(&mlog.WebhookHandlerOptions{
+		URL:      c.server.URL,
+		Template: tmpl,
+		Level:    slog.LevelWarn,
+	})
+	defer h.Close()
+
+	slog.New(h).Warn(`disk "sda" is full`)
+	tt.NoError(h.Flush(flushCtx(t)))
+
+	_, bodies := c.received()
+	tt.Len(bodies, 1)
+	tt.JSONEq(`{"alert": "disk \"sda\" is full", "count": 1}`, string(bodies[0]))
+}
+
+func Test__WebhookHandler__CircuitBreaker(t *testing.T) {
+	tt := assert.New(t)
+
+	c := newCollector(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	h := mlog.NewWebhookHandler(&mlog.WebhookHandlerOptions{
+		URL:              c.server.URL,
+		Format:           mlog.WebhookFormatGeneric,
+		ThrottleInterval: -1,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Hour,
+		Batch:            mlog.BatchOptions{MaxRetries: -1},
+	})
+	defer h.Close()
+	logger := slog.New(h)
+
+	for i := 0; i < 2; i++ {
+		logger.Error("failing")
+		tt.ErrorIs(h.Flush(flushCtx(t)), mlog.ErrUnexpectedStatus)
+	}
+	logger.Error("failing")
+	tt.ErrorIs(h.Flush(flushCtx(t)), mlog.ErrCircuitOpen)
+
+	c.mu.Lock()
+	tt.Len(c.statuses, 1) // no request was made while the breaker is open
+	c.mu.Unlock()
+	tt.EqualValues(3, h.Dropped())
+}